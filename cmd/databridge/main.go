@@ -6,9 +6,26 @@ import (
 	"github.com/Talk-Point/databridge/models"
 	"github.com/Talk-Point/databridge/pkg"
 	"github.com/Talk-Point/databridge/pkg/kestra"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/clickhouse"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/file"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/influx_line"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/parquet"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/postgres"
+	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/sqlite"
 	_ "github.com/Talk-Point/databridge/plugins/destination_plugins/timescaledb"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/camt"
 	_ "github.com/Talk-Point/databridge/plugins/source_plugins/csv_v1"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/datev"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/fixed_width"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/http_json"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/json_v1"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/mysql"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/parquet"
 	_ "github.com/Talk-Point/databridge/plugins/source_plugins/sql_api"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/sqlite"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/xlsx"
+	_ "github.com/Talk-Point/databridge/plugins/source_plugins/xml"
 
 	"github.com/Talk-Point/databridge/config"
 	"github.com/Talk-Point/databridge/plugins"
@@ -63,23 +80,54 @@ func run(flags *pkg.TimePartitionParams) {
 		}
 	}
 
-	// Fetch data
-	data, err := source.FetchData(map[string]interface{}{
+	// Template variables, flags override the config
+	vars := make(map[string]interface{})
+	for key, value := range cfg.Vars {
+		vars[key] = value
+	}
+	for key, value := range flags.Vars {
+		vars[key] = value
+	}
+
+	runOpts := map[string]interface{}{
+		"name":      cfg.Name,
 		"start_at":  flags.StartTime,
 		"end_at":    flags.EndTime,
 		"file_path": flags.FilePath,
-	})
+		"vars":      vars,
+	}
+
+	// Fetch data
+	data, err := source.FetchData(runOpts)
 	if err != nil {
 		log.Fatalf("Error fetching data: %v", err)
 		os.Exit(1)
 	}
 
+	if preparer, ok := destination.(plugins.Preparer); ok {
+		if err := preparer.Prepare(runOpts); err != nil {
+			log.Fatalf("Error preparing destination: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	// Store data
 	totalSuccess, totalErrored, err := destination.StoreData(data)
 	if err != nil {
 		log.Fatalf("Error storing data: %v", err)
 		os.Exit(1)
 	}
+	if counter, ok := source.(plugins.ErrorCounter); ok {
+		totalErrored += counter.Errored()
+	}
+
+	// Acknowledge the input only when every record was stored
+	if committer, ok := source.(plugins.Committer); ok && totalErrored == 0 {
+		if err := committer.Commit(); err != nil {
+			log.Fatalf("Error committing source: %v", err)
+			os.Exit(1)
+		}
+	}
 
 	if flags.Kestra {
 		kestra.CounterMetric("total", float64(totalSuccess)).
@@ -88,6 +136,11 @@ func run(flags *pkg.TimePartitionParams) {
 		kestra.CounterMetric("total", float64(totalErrored)).
 			WithTags(map[string]string{"status": "errored"}).
 			Log()
+		for name, count := range retry.Counts() {
+			kestra.CounterMetric("retries", float64(count)).
+				WithTags(map[string]string{"plugin": name}).
+				Log()
+		}
 	}
 	if totalErrored > 0 {
 		log.WithFields(log.Fields{
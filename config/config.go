@@ -14,6 +14,8 @@ type Config struct {
 	Source      PluginConfig `yaml:"source"`
 	Destination PluginConfig `yaml:"destination"`
 	Model       PluginConfig `yaml:"model"`
+	// Vars are available in query and path templates.
+	Vars map[string]interface{} `yaml:"vars"`
 }
 
 type PluginConfig struct {
@@ -34,3 +36,16 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	return &cfg, nil
 }
+
+// Decode converts a raw plugin config section (as captured by the inline
+// yaml map) into a typed struct using its yaml tags.
+func Decode(raw interface{}, out interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
@@ -15,10 +15,12 @@ const (
 	DateTime
 	DateTimeNullable
 	Int
+	Bool
+	JSON
 )
 
 func (ct ColumnType) String() string {
-	return [...]string{"string", "bigint", "float", "datetime", "int"}[ct]
+	return [...]string{"string", "bigint", "float", "datetime", "datetime_nullable", "int", "bool", "json"}[ct]
 }
 
 func ParseColumnType(s string) (ColumnType, error) {
@@ -35,24 +37,50 @@ func ParseColumnType(s string) (ColumnType, error) {
 		return DateTimeNullable, nil
 	case "int":
 		return Int, nil
+	case "bool":
+		return Bool, nil
+	case "json":
+		return JSON, nil
 	default:
 		return -1, fmt.Errorf("invalid column type: %s", s)
 	}
 }
 
+// Column roles for line protocol destinations.
+const (
+	RoleMeasurement = "measurement"
+	RoleTag         = "tag"
+	RoleField       = "field"
+	RoleTimestamp   = "timestamp"
+)
+
 type Column struct {
 	Name string
 	Type ColumnType
+	// Description is optional and used as column comment by destinations
+	// that support it.
+	Description string
+	// Role is optional and tells line protocol destinations whether the
+	// column is the measurement, a tag, a field or the timestamp.
+	Role string
 }
 
 type Model struct {
 	Columns []Column
 	Unique  []string
+	// Description is optional and used as table comment.
+	Description string
 }
 
 func LoadModel(data map[string]interface{}) (*Model, error) {
 	model := &Model{}
 
+	description, err := optionalString(data["description"])
+	if err != nil {
+		return nil, errors.New("invalid description format")
+	}
+	model.Description = description
+
 	columns, ok := data["columns"].([]interface{})
 	if !ok {
 		return nil, errors.New("invalid columns format")
@@ -80,9 +108,26 @@ func LoadModel(data map[string]interface{}) (*Model, error) {
 			return nil, err
 		}
 
+		description, err := optionalString(columnData["description"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid description of column %s", name)
+		}
+
+		role, err := optionalString(columnData["role"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid role of column %s", name)
+		}
+		switch role {
+		case "", RoleMeasurement, RoleTag, RoleField, RoleTimestamp:
+		default:
+			return nil, fmt.Errorf("invalid role of column %s: %s", name, role)
+		}
+
 		model.Columns = append(model.Columns, Column{
-			Name: name,
-			Type: columnType,
+			Name:        name,
+			Type:        columnType,
+			Description: description,
+			Role:        role,
 		})
 	}
 
@@ -101,3 +146,14 @@ func LoadModel(data map[string]interface{}) (*Model, error) {
 
 	return model, nil
 }
+
+func optionalString(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New("not a string")
+	}
+	return s, nil
+}
@@ -73,6 +73,57 @@ func TestLoadModel(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "descriptions",
+			data: map[string]interface{}{
+				"description": "Sales documents",
+				"columns": []interface{}{
+					map[interface{}]interface{}{
+						"name":        "id",
+						"type":        "bigint",
+						"description": "Document number",
+					},
+				},
+				"unique_key": []interface{}{"id"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid description format",
+			data: map[string]interface{}{
+				"columns": []interface{}{
+					map[interface{}]interface{}{
+						"name":        "id",
+						"type":        "bigint",
+						"description": 42,
+					},
+				},
+				"unique_key": []interface{}{"id"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "roles",
+			data: map[string]interface{}{
+				"columns": []interface{}{
+					map[interface{}]interface{}{"name": "sensor", "type": "string", "role": "tag"},
+					map[interface{}]interface{}{"name": "value", "type": "float", "role": "field"},
+					map[interface{}]interface{}{"name": "time", "type": "datetime", "role": "timestamp"},
+				},
+				"unique_key": []interface{}{"sensor", "time"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid role",
+			data: map[string]interface{}{
+				"columns": []interface{}{
+					map[interface{}]interface{}{"name": "sensor", "type": "string", "role": "label"},
+				},
+				"unique_key": []interface{}{"sensor"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "no columns provided",
 			data: map[string]interface{}{
@@ -92,3 +143,15 @@ func TestLoadModel(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnTypeString(t *testing.T) {
+	for _, name := range []string{"string", "bigint", "float", "datetime", "datetime_nullable", "int", "bool", "json"} {
+		columnType, err := ParseColumnType(name)
+		if err != nil {
+			t.Fatalf("ParseColumnType(%s) error = %v", name, err)
+		}
+		if columnType.String() != name {
+			t.Errorf("ParseColumnType(%s).String() = %s", name, columnType.String())
+		}
+	}
+}
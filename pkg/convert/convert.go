@@ -0,0 +1,204 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+// Options control how strings are parsed into column values.
+type Options struct {
+	// DateLayouts are tried in order for datetime columns.
+	DateLayouts []string
+	// Location is used for layouts without time zone, defaults to
+	// Europe/Berlin.
+	Location *time.Location
+}
+
+// ParseLocation loads the named location, the empty name returns the
+// default Europe/Berlin.
+func ParseLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = "Europe/Berlin"
+	}
+	return time.LoadLocation(name)
+}
+
+func (o Options) location() (*time.Location, error) {
+	if o.Location != nil {
+		return o.Location, nil
+	}
+	return ParseLocation("")
+}
+
+// Value converts a decoded value (e.g. from JSON) into the Go type of the
+// column. Strings are parsed with String, numbers keep their precision when
+// given as json.Number, nil stays nil and objects or arrays are stored as
+// JSON documents.
+func Value(value interface{}, columnType models.ColumnType, opts Options) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return String(v, columnType, opts)
+	case json.Number:
+		return Number(v, columnType)
+	case float64:
+		return Number(json.Number(strconv.FormatFloat(v, 'f', -1, 64)), columnType)
+	case float32:
+		return Number(json.Number(strconv.FormatFloat(float64(v), 'f', -1, 32)), columnType)
+	case int:
+		return Number(json.Number(strconv.Itoa(v)), columnType)
+	case int64:
+		return Number(json.Number(strconv.FormatInt(v, 10)), columnType)
+	case bool:
+		return Bool(v, columnType)
+	case time.Time:
+		switch columnType {
+		case models.DateTime, models.DateTimeNullable:
+			return v, nil
+		case models.String:
+			return v.Format(time.RFC3339), nil
+		default:
+			return nil, fmt.Errorf("cannot convert time to %s", columnType)
+		}
+	case map[string]interface{}, []interface{}:
+		if columnType != models.JSON && columnType != models.String {
+			return nil, fmt.Errorf("cannot convert %T to %s", value, columnType)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// String parses a string into the Go type of the column. Floats accept a
+// decimal comma, empty nullable datetimes and json values become nil.
+func String(value string, columnType models.ColumnType, opts Options) (interface{}, error) {
+	switch columnType {
+	case models.String:
+		return value, nil
+	case models.BigInt:
+		return strconv.ParseInt(value, 10, 64)
+	case models.Float:
+		return strconv.ParseFloat(strings.Replace(value, ",", ".", -1), 64)
+	case models.DateTime:
+		return parseTime(value, opts)
+	case models.DateTimeNullable:
+		if value == "" {
+			return nil, nil
+		}
+		return parseTime(value, opts)
+	case models.Int:
+		return strconv.Atoi(value)
+	case models.Bool:
+		return strconv.ParseBool(value)
+	case models.JSON:
+		if value == "" {
+			return nil, nil
+		}
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid json: %s", value)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("invalid column type: %s", columnType)
+	}
+}
+
+func parseTime(value string, opts Options) (time.Time, error) {
+	loc, err := opts.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	layouts := opts.DateLayouts
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+
+	var parseErr error
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return t, nil
+		}
+		parseErr = err
+	}
+	return time.Time{}, parseErr
+}
+
+// Number converts a JSON number into the Go type of the column.
+func Number(value json.Number, columnType models.ColumnType) (interface{}, error) {
+	switch columnType {
+	case models.String, models.JSON:
+		return value.String(), nil
+	case models.BigInt:
+		return parseInteger(value)
+	case models.Int:
+		i, err := parseInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		if i > math.MaxInt32 || i < math.MinInt32 {
+			return nil, fmt.Errorf("value %s out of range for int", value)
+		}
+		return int(i), nil
+	case models.Float:
+		return value.Float64()
+	case models.Bool:
+		i, err := parseInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		return i != 0, nil
+	default:
+		return nil, fmt.Errorf("cannot convert number to %s", columnType)
+	}
+}
+
+// parseInteger accepts integral values in float notation (e.g. 1.0 or 1e3)
+// as some APIs serialize all numbers as floats.
+func parseInteger(value json.Number) (int64, error) {
+	if i, err := value.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := value.Float64()
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("value %s is not an integer", value)
+	}
+	return int64(f), nil
+}
+
+// Bool converts a boolean into the Go type of the column.
+func Bool(value bool, columnType models.ColumnType) (interface{}, error) {
+	switch columnType {
+	case models.Bool:
+		return value, nil
+	case models.String, models.JSON:
+		return strconv.FormatBool(value), nil
+	case models.Int:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	case models.BigInt:
+		if value {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	default:
+		return nil, fmt.Errorf("cannot convert bool to %s", columnType)
+	}
+}
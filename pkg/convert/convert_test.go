@@ -0,0 +1,67 @@
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+func TestValueDateLayouts(t *testing.T) {
+	opts := Options{
+		DateLayouts: []string{time.RFC3339, "2006-01-02"},
+		Location:    time.UTC,
+	}
+
+	tests := []struct {
+		value   interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-09-25T10:00:00+02:00", time.Date(2024, 9, 25, 8, 0, 0, 0, time.UTC), false},
+		{"2024-09-25", time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC), time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC), false},
+		{"25.09.2024", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Value(tt.value, models.DateTime, opts)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Value(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !got.(time.Time).Equal(tt.want) {
+			t.Errorf("Value(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValueNumbers(t *testing.T) {
+	tests := []struct {
+		value      interface{}
+		columnType models.ColumnType
+		want       interface{}
+	}{
+		{json.Number("42"), models.Int, 42},
+		{42, models.BigInt, int64(42)},
+		{int64(42), models.Float, 42.0},
+		{3.5, models.Float, 3.5},
+		{json.Number("0"), models.Bool, false},
+		{true, models.Int, 1},
+		{true, models.BigInt, int64(1)},
+		{false, models.BigInt, int64(0)},
+	}
+
+	for _, tt := range tests {
+		got, err := Value(tt.value, tt.columnType, Options{})
+		if err != nil {
+			t.Fatalf("Value(%v) error = %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("Value(%v, %s) = %#v, want %#v", tt.value, tt.columnType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package csvreader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dialect describes the format of a delimited text file.
+type Dialect struct {
+	// Delimiter separates the fields, defaults to a comma.
+	Delimiter rune
+	// Quote encloses fields containing delimiters or line breaks, 0
+	// disables quoting.
+	Quote rune
+	// Escape escapes the next character inside quoted fields. When equal to
+	// Quote a doubled quote is a literal quote (RFC 4180).
+	Escape rune
+	// Comment starts lines that are ignored, 0 disables comments.
+	Comment rune
+	// Trim removes leading and trailing white space of fields, white space
+	// before an opening quote is ignored.
+	Trim bool
+}
+
+// DefaultDialect is RFC 4180 with a comma delimiter.
+func DefaultDialect() Dialect {
+	return Dialect{Delimiter: ',', Quote: '"', Escape: '"'}
+}
+
+// ParseRune reads a single character option, `\t` and `tab` are accepted
+// for the tab character and the empty string disables the option.
+func ParseRune(name, value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) {
+		return 0, fmt.Errorf("%s must be a single character, got %q", name, value)
+	}
+	return r, nil
+}
+
+var ErrUnterminatedQuote = errors.New("unterminated quoted field")
+
+// ParseError reports the line of a malformed record.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads records from a delimited text file.
+type Reader struct {
+	dialect Dialect
+	r       *bufio.Reader
+	line    int
+}
+
+func New(r io.Reader, dialect Dialect) *Reader {
+	if dialect.Delimiter == 0 {
+		dialect.Delimiter = ','
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{dialect: dialect, r: br}
+}
+
+// Line returns the line number of the last record read.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Read returns the next record. Empty lines and comments are skipped, io.EOF
+// is returned at the end of the input.
+func (r *Reader) Read() ([]string, error) {
+	for {
+		record, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			return record, nil
+		}
+	}
+}
+
+// readRecord reads one line (or several for quoted line breaks), it returns
+// a nil record for empty lines and comments.
+func (r *Reader) readRecord() ([]string, error) {
+	d := r.dialect
+	r.line++
+	startLine := r.line
+
+	var (
+		fields   []string
+		field    strings.Builder
+		quoted   bool
+		inQuotes bool
+		readAny  bool
+	)
+
+	endField := func() {
+		value := field.String()
+		if d.Trim {
+			value = strings.TrimSpace(value)
+		}
+		fields = append(fields, value)
+		field.Reset()
+		quoted = false
+	}
+
+	for {
+		ch, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, &ParseError{Line: startLine, Err: ErrUnterminatedQuote}
+			}
+			if !readAny {
+				return nil, io.EOF
+			}
+			endField()
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !readAny && d.Comment != 0 && ch == d.Comment {
+			if _, err := r.r.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, nil
+		}
+		readAny = true
+
+		if inQuotes {
+			switch {
+			case ch == d.Quote && d.Escape == d.Quote:
+				next, _, err := r.r.ReadRune()
+				if err == nil && next == d.Quote {
+					field.WriteRune(d.Quote)
+					continue
+				}
+				if err == nil {
+					r.r.UnreadRune()
+				}
+				inQuotes = false
+			case ch == d.Quote:
+				inQuotes = false
+			case d.Escape != 0 && ch == d.Escape:
+				next, _, err := r.r.ReadRune()
+				if err != nil {
+					return nil, &ParseError{Line: startLine, Err: ErrUnterminatedQuote}
+				}
+				if next == '\n' {
+					r.line++
+				}
+				field.WriteRune(next)
+			default:
+				if ch == '\n' {
+					r.line++
+				}
+				field.WriteRune(ch)
+			}
+			continue
+		}
+
+		switch {
+		case d.Quote != 0 && ch == d.Quote && !quoted && (field.Len() == 0 || d.Trim && strings.TrimSpace(field.String()) == ""):
+			field.Reset()
+			inQuotes = true
+			quoted = true
+		case ch == d.Delimiter:
+			endField()
+		case ch == '\r' || ch == '\n':
+			if ch == '\r' {
+				next, _, err := r.r.ReadRune()
+				if err == nil && next != '\n' {
+					r.r.UnreadRune()
+				}
+			}
+			// a line without any content is skipped
+			if len(fields) == 0 && field.Len() == 0 && !quoted {
+				return nil, nil
+			}
+			endField()
+			return fields, nil
+		default:
+			field.WriteRune(ch)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package csvreader
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, input string, dialect Dialect) ([][]string, error) {
+	reader := New(strings.NewReader(input), dialect)
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		dialect Dialect
+		want    [][]string
+	}{
+		{
+			name:    "rfc 4180",
+			input:   "a,b,c\n1,\"x, \"\"y\"\"\",3\r\n",
+			dialect: DefaultDialect(),
+			want:    [][]string{{"a", "b", "c"}, {"1", `x, "y"`, "3"}},
+		},
+		{
+			name:    "semicolon with multi line field",
+			input:   "nr;text\n1;\"line1\nline2\"\n",
+			dialect: Dialect{Delimiter: ';', Quote: '"', Escape: '"'},
+			want:    [][]string{{"nr", "text"}, {"1", "line1\nline2"}},
+		},
+		{
+			name:    "backslash escape and single quote",
+			input:   `1|'it\'s'|x` + "\n",
+			dialect: Dialect{Delimiter: '|', Quote: '\'', Escape: '\\'},
+			want:    [][]string{{"1", "it's", "x"}},
+		},
+		{
+			name:    "comments and empty lines",
+			input:   "# export\n\na,b\n# trailer\n1,2",
+			dialect: Dialect{Delimiter: ',', Quote: '"', Escape: '"', Comment: '#'},
+			want:    [][]string{{"a", "b"}, {"1", "2"}},
+		},
+		{
+			name:    "trim",
+			input:   " a ;  \"b\" ; c \n",
+			dialect: Dialect{Delimiter: ';', Quote: '"', Escape: '"', Trim: true},
+			want:    [][]string{{"a", "b", "c"}},
+		},
+		{
+			name:    "quoting disabled",
+			input:   "a\t\"b\n",
+			dialect: Dialect{Delimiter: '\t'},
+			want:    [][]string{{"a", `"b`}},
+		},
+		{
+			name:    "empty trailing field",
+			input:   "a;;\n",
+			dialect: Dialect{Delimiter: ';', Quote: '"', Escape: '"'},
+			want:    [][]string{{"a", "", ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.input, tt.dialect)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadUnterminatedQuote(t *testing.T) {
+	_, err := readAll(t, "a,b\n1,\"open\n", DefaultDialect())
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrUnterminatedQuote) || parseErr.Line != 2 {
+		t.Errorf("expected unterminated quote on line 2, got %v", err)
+	}
+}
+
+func TestParseRune(t *testing.T) {
+	if r, _ := ParseRune("delimiter", `\t`); r != '\t' {
+		t.Errorf("expected tab, got %q", r)
+	}
+	if r, _ := ParseRune("delimiter", ";"); r != ';' {
+		t.Errorf("expected semicolon, got %q", r)
+	}
+	if _, err := ParseRune("delimiter", ";;"); err == nil {
+		t.Error("expected error for multiple characters")
+	}
+}
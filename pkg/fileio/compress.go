@@ -0,0 +1,194 @@
+package fileio
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionAuto  = "auto"
+	CompressionNone  = "none"
+	CompressionGzip  = "gzip"
+	CompressionZstd  = "zstd"
+	CompressionBzip2 = "bzip2"
+	CompressionZip   = "zip"
+)
+
+// CompressionOptions describe how compressed input is unpacked.
+type CompressionOptions struct {
+	// Compression is auto (default), none, gzip, zstd, bzip2 or zip. Auto
+	// detects it by the file extension or the magic bytes.
+	Compression string `yaml:"compression"`
+	// Members selects the files of a zip archive by a glob on their name,
+	// defaults to all files.
+	Members string `yaml:"members"`
+}
+
+// Validate checks the compression and the member pattern.
+func (o CompressionOptions) Validate() error {
+	switch o.Compression {
+	case "", CompressionAuto, CompressionNone, CompressionGzip, CompressionZstd, CompressionBzip2, CompressionZip:
+	default:
+		return fmt.Errorf("unsupported compression: %s", o.Compression)
+	}
+	if o.Members != "" {
+		if _, err := path.Match(o.Members, ""); err != nil {
+			return fmt.Errorf("invalid members pattern %q: %v", o.Members, err)
+		}
+	}
+	return nil
+}
+
+var magics = []struct {
+	compression string
+	magic       []byte
+}{
+	{CompressionGzip, []byte{0x1f, 0x8b}},
+	{CompressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{CompressionBzip2, []byte("BZh")},
+	{CompressionZip, []byte("PK\x03\x04")},
+}
+
+// DetectCompression returns the compression of a file by its extension or,
+// for unknown extensions, by the leading bytes of its content.
+func DetectCompression(name string, head []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".gzip":
+		return CompressionGzip
+	case ".zst", ".zstd":
+		return CompressionZstd
+	case ".bz2":
+		return CompressionBzip2
+	case ".zip":
+		return CompressionZip
+	}
+	for _, m := range magics {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.compression
+		}
+	}
+	return CompressionNone
+}
+
+// Open opens a local file and calls fn with the decompressed content. For zip
+// archives fn is called for every selected member in archive order.
+func Open(filePath string, opts CompressionOptions, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compression := opts.Compression
+	if compression == "" || compression == CompressionAuto {
+		head := make([]byte, 4)
+		n, err := file.ReadAt(head, 0)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		compression = DetectCompression(filePath, head[:n])
+	}
+
+	if compression == CompressionZip {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		return openZip(file, info.Size(), opts.Members, fn)
+	}
+
+	r, err := decompress(file, compression)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+	defer r.Close()
+	return fn(filePath, r)
+}
+
+// Decompress unpacks a stream, name is used to detect the compression. Zip
+// archives need random access and are not supported, use Open instead.
+func Decompress(r io.Reader, name string, opts CompressionOptions) (io.ReadCloser, error) {
+	compression := opts.Compression
+	if compression == "" || compression == CompressionAuto {
+		br := bufio.NewReader(r)
+		head, err := br.Peek(4)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		compression = DetectCompression(name, head)
+		r = br
+	}
+	if compression == CompressionZip {
+		return nil, fmt.Errorf("%s: zip archives can not be streamed", name)
+	}
+	return decompress(r, compression)
+}
+
+func decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+func openZip(r io.ReaderAt, size int64, members string, fn func(name string, r io.Reader) error) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, member := range archive.File {
+		if member.FileInfo().IsDir() {
+			continue
+		}
+		if members != "" {
+			// match the full name or the base name of nested members
+			full, _ := path.Match(members, member.Name)
+			base, _ := path.Match(members, path.Base(member.Name))
+			if !full && !base {
+				continue
+			}
+		}
+		found = true
+
+		if err := openMember(member, fn); err != nil {
+			return fmt.Errorf("%s: %v", member.Name, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no zip member matches %q", members)
+	}
+	return nil
+}
+
+func openMember(member *zip.File, fn func(name string, r io.Reader) error) error {
+	rc, err := member.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return fn(member.Name, rc)
+}
@@ -0,0 +1,134 @@
+package fileio
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const content = "mandant,time,sensor,value\n1,2024-09-01T12:00:00,a,1.5\n"
+
+func gzipData(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipData(t *testing.T, members ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range members {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(name + ":" + content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	bz2, err := os.ReadFile("testdata/ticks.csv.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zipData(t, "export/ticks_1.csv", "export/readme.txt", "export/ticks_2.csv")
+
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		opts    CompressionOptions
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain", file: "ticks.csv", data: []byte(content), want: []string{content}},
+		{name: "gzip by extension", file: "ticks.csv.gz", data: gzipData(t), want: []string{content}},
+		{name: "gzip by magic bytes", file: "ticks.csv", data: gzipData(t), want: []string{content}},
+		{name: "zstd", file: "ticks.csv.zst", data: zstdData(t), want: []string{content}},
+		{name: "zstd by magic bytes", file: "ticks.dat", data: zstdData(t), want: []string{content}},
+		{name: "bzip2", file: "ticks.csv.bz2", data: bz2, want: []string{content}},
+		{name: "explicit none", file: "ticks.csv.gz", data: []byte(content), opts: CompressionOptions{Compression: "none"}, want: []string{content}},
+		{
+			name: "zip members",
+			file: "export.zip",
+			data: archive,
+			opts: CompressionOptions{Members: "*.csv"},
+			want: []string{"export/ticks_1.csv:" + content, "export/ticks_2.csv:" + content},
+		},
+		{name: "zip without matching member", file: "export.zip", data: archive, opts: CompressionOptions{Members: "*.json"}, wantErr: true},
+		{name: "broken gzip", file: "ticks.csv.gz", data: []byte("nope"), wantErr: true},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.opts.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			err := Open(path, tt.opts, func(name string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				got = append(got, string(data))
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Open() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	r, err := Decompress(bytes.NewReader(gzipData(t)), "ticks.csv", CompressionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != content {
+		t.Errorf("Decompress() = %q, %v", data, err)
+	}
+
+	if _, err := Decompress(strings.NewReader("PK\x03\x04"), "export", CompressionOptions{}); err == nil {
+		t.Error("expected error for zip streams")
+	}
+	if err := (CompressionOptions{Compression: "lzma"}).Validate(); err == nil {
+		t.Error("expected error for unsupported compression")
+	}
+}
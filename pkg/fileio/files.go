@@ -0,0 +1,601 @@
+package fileio
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/pkg/objstore"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileOptions select the input files of a file source.
+type FileOptions struct {
+	// Path is a file, a directory or a glob pattern.
+	Path string `yaml:"path"`
+	// Pattern filters the files of a directory, defaults to all files.
+	Pattern string `yaml:"pattern"`
+	// Recursive includes the files of sub directories.
+	Recursive bool `yaml:"recursive"`
+	// ArchiveDir receives the files after they were stored successfully.
+	ArchiveDir string `yaml:"archive_dir"`
+	// ErrorDir receives the files that could not be read. Without it a
+	// broken file fails the run.
+	ErrorDir string `yaml:"error_dir"`
+	// StateFile tracks the loaded files by name and checksum (the ETag of
+	// objects), files already in the state are skipped.
+	StateFile string `yaml:"state_file"`
+	// S3 reads the files from a bucket instead of the local file system.
+	// Path is then a key, a key prefix or a glob on the keys, ArchiveDir
+	// and ErrorDir are key prefixes in the same bucket.
+	S3 *objstore.Options `yaml:"s3"`
+}
+
+// File is an input file selected by Files.
+type File struct {
+	Path string
+	// Checksum is the SHA-256 of local files and the ETag of objects.
+	Checksum string
+	// Key is the object key of files read from S3, Path is then its s3://
+	// URL.
+	Key string
+}
+
+// Files lists the input files and archives them once they are loaded.
+type Files struct {
+	Options FileOptions
+
+	state  *State
+	loaded []File
+	store  *objstore.Store
+	// skipped counts the dropped records of the file being read, errored
+	// those of all files.
+	skipped int
+	errored int
+}
+
+func NewFiles(opts FileOptions) (*Files, error) {
+	if opts.Pattern != "" {
+		if _, err := filepath.Match(opts.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", opts.Pattern, err)
+		}
+	}
+	f := &Files{Options: opts}
+	if opts.StateFile != "" {
+		state, err := LoadState(opts.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		f.state = state
+	}
+	if opts.S3 != nil {
+		store, err := objstore.New(*opts.S3)
+		if err != nil {
+			return nil, err
+		}
+		f.store = store
+	}
+	return f, nil
+}
+
+// List returns the files to load in name order. A non empty path overrides
+// the configured one. Files already recorded in the state are skipped.
+func (f *Files) List(path string) ([]File, error) {
+	if path == "" {
+		path = f.Options.Path
+	}
+	if path == "" {
+		return nil, errors.New("file path is missing")
+	}
+	if f.store != nil {
+		return f.listObjects(path)
+	}
+
+	paths, err := f.match(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(paths))
+	for _, p := range paths {
+		file := File{Path: p}
+		if f.state != nil {
+			if file.Checksum, err = Checksum(p); err != nil {
+				return nil, err
+			}
+			if f.state.Loaded(filepath.Base(p), file.Checksum) {
+				log.WithField("file", p).Info("Skipping file, already loaded")
+				continue
+			}
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (f *Files) match(path string) ([]string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		paths, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", path, err)
+		}
+		var files []string
+		for _, p := range paths {
+			if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+				files = append(files, p)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && !f.Options.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if f.Options.Pattern != "" {
+			if ok, _ := filepath.Match(f.Options.Pattern, d.Name()); !ok {
+				return nil
+			}
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Failed handles a file that could not be read. With an error directory the
+// file is moved there and nil is returned, otherwise err is returned.
+func (f *Files) Failed(file File, err error) error {
+	if file.Key != "" {
+		return f.failedObject(file, err)
+	}
+	if f.Options.ErrorDir == "" {
+		return fmt.Errorf("%s: %v", file.Path, err)
+	}
+	log.WithError(err).WithField("file", file.Path).Error("Error reading file")
+	target, moveErr := Move(file.Path, f.Options.ErrorDir)
+	if moveErr != nil {
+		return fmt.Errorf("error moving %s to error_dir: %v", file.Path, moveErr)
+	}
+	log.WithField("file", target).Info("Moved file to error_dir")
+	return nil
+}
+
+// Loaded marks a file as read, it is archived and recorded by Commit.
+func (f *Files) Loaded(file File) {
+	f.loaded = append(f.loaded, file)
+}
+
+// Emit receives the records of a file.
+type Emit = func(record map[string]interface{})
+
+// Read lists the files of path (see List) and reads them one after another.
+// The records of a file are only kept when it was read completely without
+// skipped records, files that fail are handed to Failed and the others are
+// marked as loaded. S3
+// objects are downloaded to a temporary file for read, which is removed
+// right after.
+func (f *Files) Read(path string, read func(file File, emit Emit) error) ([]map[string]interface{}, error) {
+	return f.each(path, func(file File, emit Emit) error {
+		if file.Key == "" {
+			return read(file, emit)
+		}
+		object, err := f.store.Get(file.Key)
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		return f.spool(file.Key, object, func(local string) error {
+			file.Path = local
+			return read(file, emit)
+		})
+	})
+}
+
+// ReadStreams is Read for sources parsing the decompressed content, read is
+// called for the file or for every selected member of a zip archive. S3
+// objects are streamed, only zip archives are downloaded.
+func (f *Files) ReadStreams(path string, opts CompressionOptions, read func(r io.Reader, emit Emit) error) ([]map[string]interface{}, error) {
+	return f.each(path, func(file File, emit Emit) error {
+		fn := func(_ string, r io.Reader) error {
+			return read(r, emit)
+		}
+		if file.Key != "" {
+			return f.openObject(file.Key, opts, fn)
+		}
+		return Open(file.Path, opts, fn)
+	})
+}
+
+func (f *Files) each(path string, read func(file File, emit Emit) error) ([]map[string]interface{}, error) {
+	files, err := f.List(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for _, file := range files {
+		log.WithField("file", file.Path).Info("Reading file")
+		var fileRecords []map[string]interface{}
+		f.skipped = 0
+		err := read(file, func(record map[string]interface{}) {
+			fileRecords = append(fileRecords, record)
+		})
+		f.errored += f.skipped
+		if err == nil && f.skipped > 0 {
+			err = fmt.Errorf("%d records could not be read", f.skipped)
+		}
+		if err != nil {
+			if err := f.Failed(file, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		records = append(records, fileRecords...)
+		f.Loaded(file)
+	}
+	return records, nil
+}
+
+// Skip counts a record of the file being read that was dropped because it
+// could not be parsed or transformed. Read hands files with dropped records
+// to Failed, so they are neither archived nor recorded in the state.
+func (f *Files) Skip() {
+	f.skipped++
+}
+
+// Errored returns the number of records dropped by Skip.
+func (f *Files) Errored() int {
+	return f.errored
+}
+
+// Commit archives the loaded files and records them in the state. It is
+// called after the data was stored, sources embedding Files acknowledge
+// their input with it.
+func (f *Files) Commit() error {
+	for _, file := range f.loaded {
+		if f.state != nil {
+			f.state.Add(filepath.Base(file.Path), file.Checksum)
+		}
+		if file.Key != "" {
+			if err := f.archiveObject(file); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Options.ArchiveDir != "" {
+			target, err := Move(file.Path, f.Options.ArchiveDir)
+			if err != nil {
+				return fmt.Errorf("error moving %s to archive_dir: %v", file.Path, err)
+			}
+			log.WithField("file", target).Info("Archived file")
+		}
+	}
+	f.loaded = nil
+
+	if f.state != nil {
+		return f.state.Save()
+	}
+	return nil
+}
+
+// listObjects lists the matching objects without reading them, objects
+// whose ETag is recorded in the state are skipped.
+func (f *Files) listObjects(pattern string) ([]File, error) {
+	objects, err := f.matchObjects(strings.TrimPrefix(pattern, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(objects))
+	for _, object := range objects {
+		file := File{
+			Path:     f.store.URL(object.Key),
+			Checksum: strings.Trim(object.ETag, `"`),
+			Key:      object.Key,
+		}
+		if f.state != nil && f.state.Loaded(path.Base(object.Key), file.Checksum) {
+			log.WithField("file", file.Path).Info("Skipping file, already loaded")
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// matchObjects returns a single object, the objects below a prefix or the
+// objects matching a glob. Like a missing local path, a key or prefix
+// without any object is an error.
+func (f *Files) matchObjects(pattern string) ([]objstore.Object, error) {
+	if strings.ContainsAny(pattern, "*?[") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+		}
+		prefix := pattern[:strings.IndexAny(pattern, "*?[")]
+		objects, err := f.store.List(prefix, true)
+		if err != nil {
+			return nil, err
+		}
+		var matches []objstore.Object
+		for _, object := range objects {
+			if ok, _ := path.Match(pattern, object.Key); ok {
+				matches = append(matches, object)
+			}
+		}
+		return matches, nil
+	}
+
+	if !strings.HasSuffix(pattern, "/") {
+		object, err := f.store.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if object != nil {
+			return []objstore.Object{*object}, nil
+		}
+		pattern += "/"
+	}
+
+	objects, err := f.store.List(pattern, f.Options.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		// an empty prefix only exists with a folder marker or sub prefixes
+		exists, err := f.store.HasPrefix(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s: no such key or prefix", f.store.URL(strings.TrimSuffix(pattern, "/")))
+		}
+	}
+	var matches []objstore.Object
+	for _, object := range objects {
+		if f.Options.Pattern != "" {
+			if ok, _ := filepath.Match(f.Options.Pattern, path.Base(object.Key)); !ok {
+				continue
+			}
+		}
+		matches = append(matches, object)
+	}
+	return matches, nil
+}
+
+// openObject is Open for an object. The content is decompressed while it is
+// streamed, zip archives need random access and are downloaded.
+func (f *Files) openObject(key string, opts CompressionOptions, fn func(name string, r io.Reader) error) error {
+	object, err := f.store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	br := bufio.NewReader(object)
+	compression := opts.Compression
+	if compression == "" || compression == CompressionAuto {
+		head, err := br.Peek(4)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		compression = DetectCompression(key, head)
+	}
+
+	if compression == CompressionZip {
+		return f.spool(key, br, func(local string) error {
+			return Open(local, CompressionOptions{Compression: CompressionZip, Members: opts.Members}, fn)
+		})
+	}
+
+	r, err := decompress(br, compression)
+	if err != nil {
+		return fmt.Errorf("%s: %v", f.store.URL(key), err)
+	}
+	defer r.Close()
+	return fn(key, r)
+}
+
+// spool writes the content of an object to a temporary file, calls fn with
+// its path and removes it again.
+func (f *Files) spool(key string, r io.Reader, fn func(local string) error) error {
+	out, err := os.CreateTemp("", "databridge-*-"+path.Base(key))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	size, err := io.Copy(out, r)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"file": f.store.URL(key),
+		"size": size,
+	}).Info("Downloaded file")
+	return fn(out.Name())
+}
+
+func (f *Files) failedObject(file File, err error) error {
+	if f.Options.ErrorDir == "" {
+		return fmt.Errorf("%s: %v", f.store.URL(file.Key), err)
+	}
+	log.WithError(err).WithField("file", f.store.URL(file.Key)).Error("Error reading file")
+	target, moveErr := f.store.Move(file.Key, f.Options.ErrorDir)
+	if moveErr != nil {
+		return fmt.Errorf("error moving %s to error_dir: %v", f.store.URL(file.Key), moveErr)
+	}
+	log.WithField("file", f.store.URL(target)).Info("Moved file to error_dir")
+	return nil
+}
+
+func (f *Files) archiveObject(file File) error {
+	if f.Options.ArchiveDir == "" {
+		return nil
+	}
+	target, err := f.store.Move(file.Key, f.Options.ArchiveDir)
+	if err != nil {
+		return fmt.Errorf("error moving %s to archive_dir: %v", f.store.URL(file.Key), err)
+	}
+	log.WithField("file", f.store.URL(target)).Info("Archived file")
+	return nil
+}
+
+// Checksum returns the hex encoded SHA-256 of the file.
+func Checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Move moves the file into dir, which is created if missing. An existing
+// file with the same name is kept and the moved file gets a timestamp
+// suffix. It returns the new path.
+func Move(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(target); err == nil {
+		target = fmt.Sprintf("%s.%s", target, time.Now().Format("20060102T150405"))
+	}
+
+	if err := os.Rename(path, target); err == nil {
+		return target, nil
+	}
+
+	// rename fails across file systems, fall back to copy and remove
+	if err := copyFile(path, target); err != nil {
+		return "", err
+	}
+	return target, os.Remove(path)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// StateEntry is a loaded file.
+type StateEntry struct {
+	Name     string    `json:"name"`
+	Checksum string    `json:"checksum"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// State is the JSON file recording the loaded files.
+type State struct {
+	Files []StateEntry `json:"files"`
+
+	path string
+}
+
+// LoadState reads the state file, a missing file is an empty state.
+func LoadState(path string) (*State, error) {
+	state := &State{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// Loaded reports whether the file was loaded before.
+func (s *State) Loaded(name, checksum string) bool {
+	for _, entry := range s.Files {
+		if entry.Name == name && entry.Checksum == checksum {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *State) Add(name, checksum string) {
+	if s.Loaded(name, checksum) {
+		return
+	}
+	s.Files = append(s.Files, StateEntry{Name: name, Checksum: checksum, LoadedAt: time.Now()})
+}
+
+// Save writes the state through a temporary file, so an interrupted run
+// keeps the previous state.
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
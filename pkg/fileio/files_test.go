@@ -0,0 +1,228 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func paths(dir string, files []File) []string {
+	var result []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file.Path)
+		result = append(result, filepath.ToSlash(rel))
+	}
+	return result
+}
+
+func TestFilesList(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"b.csv":     "b",
+		"a.csv":     "a",
+		"notes.txt": "n",
+		"sub/c.csv": "c",
+	})
+
+	tests := []struct {
+		name string
+		opts FileOptions
+		path string
+		want []string
+	}{
+		{"single file", FileOptions{Path: filepath.Join(dir, "a.csv")}, "", []string{"a.csv"}},
+		{"glob", FileOptions{}, filepath.Join(dir, "*.csv"), []string{"a.csv", "b.csv"}},
+		{"directory", FileOptions{Path: dir}, "", []string{"a.csv", "b.csv", "notes.txt"}},
+		{"directory with pattern", FileOptions{Path: dir, Pattern: "*.csv"}, "", []string{"a.csv", "b.csv"}},
+		{"recursive", FileOptions{Path: dir, Pattern: "*.csv", Recursive: true}, "", []string{"a.csv", "b.csv", "sub/c.csv"}},
+		{"path overrides config", FileOptions{Path: dir}, filepath.Join(dir, "b.csv"), []string{"b.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := NewFiles(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := files.List(tt.path)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !reflect.DeepEqual(paths(dir, got), tt.want) {
+				t.Errorf("List() = %v, want %v", paths(dir, got), tt.want)
+			}
+		})
+	}
+
+	files, _ := NewFiles(FileOptions{})
+	if _, err := files.List(""); err == nil {
+		t.Error("expected error without path")
+	}
+}
+
+func TestFilesCommit(t *testing.T) {
+	dir := t.TempDir()
+	inbox := filepath.Join(dir, "inbox")
+	writeFiles(t, inbox, map[string]string{"a.csv": "a", "b.csv": "b", "broken.csv": "x"})
+
+	opts := FileOptions{
+		Path:       inbox,
+		ArchiveDir: filepath.Join(dir, "archive"),
+		ErrorDir:   filepath.Join(dir, "error"),
+		StateFile:  filepath.Join(dir, "state", "loaded.json"),
+	}
+	files, err := NewFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := files.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range list {
+		if filepath.Base(file.Path) == "broken.csv" {
+			if err := files.Failed(file, os.ErrInvalid); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		files.Loaded(file)
+	}
+	if err := files.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	for _, path := range []string{"archive/a.csv", "archive/b.csv", "error/broken.csv", "state/loaded.json"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("expected %s: %v", path, err)
+		}
+	}
+
+	// the same export dropped again is skipped, a changed one is loaded
+	writeFiles(t, inbox, map[string]string{"a.csv": "a", "b.csv": "b2"})
+	files, err = NewFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = files.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(inbox, list); !reflect.DeepEqual(got, []string{"b.csv"}) {
+		t.Errorf("List() after commit = %v, want [b.csv]", got)
+	}
+	files.Loaded(list[0])
+	if err := files.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "archive")); len(entries) != 3 {
+		t.Errorf("expected the second b.csv archived next to the first, got %d files", len(entries))
+	}
+}
+
+func TestFailedWithoutErrorDir(t *testing.T) {
+	files, _ := NewFiles(FileOptions{})
+	if err := files.Failed(File{Path: "a.csv"}, os.ErrInvalid); err == nil {
+		t.Error("expected error without error_dir")
+	}
+}
+
+func TestFilesRead(t *testing.T) {
+	dir := t.TempDir()
+	inbox := filepath.Join(dir, "inbox")
+	writeFiles(t, inbox, map[string]string{"a.txt": "a1\na2", "b.txt": "b1\nbroken", "c.txt": "c1"})
+
+	files, err := NewFiles(FileOptions{Path: inbox, ErrorDir: filepath.Join(dir, "error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := files.ReadStreams("", CompressionOptions{}, func(r io.Reader, emit Emit) error {
+		data, _ := io.ReadAll(r)
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "broken" {
+				return errors.New("broken line")
+			}
+			emit(map[string]interface{}{"line": line})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadStreams() error = %v", err)
+	}
+
+	// the records read from b.txt before the error are dropped
+	var lines []string
+	for _, record := range records {
+		lines = append(lines, record["line"].(string))
+	}
+	if want := []string{"a1", "a2", "c1"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("records = %v, want %v", lines, want)
+	}
+	if got := paths(inbox, files.loaded); !reflect.DeepEqual(got, []string{"a.txt", "c.txt"}) {
+		t.Errorf("loaded = %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "error", "b.txt")); err != nil {
+		t.Errorf("expected b.txt in error_dir: %v", err)
+	}
+}
+
+func TestFilesReadSkipped(t *testing.T) {
+	dir := t.TempDir()
+	inbox := filepath.Join(dir, "inbox")
+	writeFiles(t, inbox, map[string]string{"a.txt": "a1\nbad\na2", "b.txt": "b1"})
+
+	files, err := NewFiles(FileOptions{
+		Path:       inbox,
+		ArchiveDir: filepath.Join(dir, "archive"),
+		ErrorDir:   filepath.Join(dir, "error"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := files.ReadStreams("", CompressionOptions{}, func(r io.Reader, emit Emit) error {
+		data, _ := io.ReadAll(r)
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "bad" {
+				files.Skip()
+				continue
+			}
+			emit(map[string]interface{}{"line": line})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadStreams() error = %v", err)
+	}
+	if len(records) != 1 || records[0]["line"] != "b1" {
+		t.Errorf("records = %v", records)
+	}
+	if files.Errored() != 1 {
+		t.Errorf("Errored() = %d, want 1", files.Errored())
+	}
+	if err := files.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "error", "a.txt")); err != nil {
+		t.Errorf("expected a.txt in error_dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("a.txt was archived")
+	}
+}
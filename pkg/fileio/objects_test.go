@@ -0,0 +1,183 @@
+package fileio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/objstore"
+	"github.com/Talk-Point/databridge/pkg/objstore/s3test"
+)
+
+func s3Options(server *s3test.Server) *objstore.Options {
+	return &objstore.Options{
+		Endpoint:  server.URL,
+		Region:    "eu-central-1",
+		Bucket:    "drops",
+		AccessKey: httpauth.Secret{Value: "minio"},
+		SecretKey: httpauth.Secret{Value: "minio123"},
+		PathStyle: true,
+	}
+}
+
+func keys(files []File) []string {
+	var result []string
+	for _, file := range files {
+		result = append(result, file.Key)
+	}
+	return result
+}
+
+func TestFilesListObjects(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	for _, key := range []string{"in/b.csv", "in/a.csv", "in/notes.txt", "in/sub/c.csv", "empty/"} {
+		server.Put("drops", key, []byte(key))
+	}
+
+	tests := []struct {
+		name    string
+		opts    FileOptions
+		want    []string
+		wantErr bool
+	}{
+		{"single object", FileOptions{Path: "in/a.csv"}, []string{"in/a.csv"}, false},
+		{"glob", FileOptions{Path: "in/*.csv"}, []string{"in/a.csv", "in/b.csv"}, false},
+		{"prefix", FileOptions{Path: "in"}, []string{"in/a.csv", "in/b.csv", "in/notes.txt"}, false},
+		{"prefix with pattern", FileOptions{Path: "/in/", Pattern: "*.csv", Recursive: true}, []string{"in/a.csv", "in/b.csv", "in/sub/c.csv"}, false},
+		{"no match", FileOptions{Path: "in/", Pattern: "*.json"}, nil, false},
+		{"folder marker", FileOptions{Path: "empty"}, nil, false},
+		{"missing key", FileOptions{Path: "in/x.csv"}, nil, true},
+		{"missing prefix", FileOptions{Path: "out/"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.S3 = s3Options(server)
+			files, err := NewFiles(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := files.List("")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(keys(got), tt.want) {
+				t.Errorf("List() = %v, want %v", keys(got), tt.want)
+			}
+			for _, file := range got {
+				if file.Path != "s3://drops/"+file.Key || file.Checksum == "" {
+					t.Errorf("List() file = %+v", file)
+				}
+			}
+		})
+	}
+}
+
+func TestFilesReadObjects(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	server.Put("drops", "in/a.csv", []byte(content))
+	server.Put("drops", "in/b.csv.gz", gzipData(t))
+	server.Put("drops", "in/c.zip", zipData(t, "c1.csv", "c2.csv"))
+
+	files, err := NewFiles(FileOptions{Path: "in/", S3: s3Options(server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := files.ReadStreams("", CompressionOptions{}, func(r io.Reader, emit Emit) error {
+		data, err := io.ReadAll(r)
+		emit(map[string]interface{}{"data": string(data)})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadStreams() error = %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, record["data"].(string))
+	}
+	want := []string{content, content, "c1.csv:" + content, "c2.csv:" + content}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadStreams() = %q, want %q", got, want)
+	}
+
+	// Read hands out a local copy that is removed after the file was read
+	var locals []string
+	_, err = files.Read("in/a.csv", func(file File, emit Emit) error {
+		locals = append(locals, file.Path)
+		data, err := os.ReadFile(file.Path)
+		if err == nil && string(data) != content {
+			t.Errorf("local copy of %s = %q", file.Key, data)
+		}
+		return err
+	})
+	if err != nil || len(locals) != 1 {
+		t.Fatalf("Read() = %v, %v", locals, err)
+	}
+	if _, err := os.Stat(locals[0]); !os.IsNotExist(err) {
+		t.Errorf("local copy %s was not removed", locals[0])
+	}
+}
+
+func TestFilesCommitObjects(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	for _, key := range []string{"in/a.csv", "in/b.csv", "in/broken.csv"} {
+		server.Put("drops", key, []byte(key))
+	}
+
+	dir := t.TempDir()
+	opts := FileOptions{
+		Path:       "in/",
+		ArchiveDir: "archive",
+		ErrorDir:   "error",
+		StateFile:  filepath.Join(dir, "loaded.json"),
+		S3:         s3Options(server),
+	}
+	files, err := NewFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := files.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range list {
+		if file.Key == "in/broken.csv" {
+			if err := files.Failed(file, os.ErrInvalid); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		files.Loaded(file)
+	}
+	if err := files.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	want := []string{"archive/a.csv", "archive/b.csv", "error/broken.csv"}
+	if got := server.Keys("drops"); !reflect.DeepEqual(got, want) {
+		t.Errorf("keys after commit = %v, want %v", got, want)
+	}
+
+	// the same export dropped again is skipped by its ETag
+	server.Put("drops", "in/a.csv", []byte("in/a.csv"))
+	files, err = NewFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, err = files.List(""); err != nil || len(list) != 0 {
+		t.Errorf("List() after commit = %v, %v", keys(list), err)
+	}
+
+	files, _ = NewFiles(FileOptions{S3: s3Options(server)})
+	err = files.Failed(File{Path: "s3://drops/in/x.csv", Key: "in/x.csv"}, os.ErrInvalid)
+	if err == nil || !strings.Contains(err.Error(), "s3://drops/in/x.csv") {
+		t.Errorf("Failed() without error_dir = %v", err)
+	}
+}
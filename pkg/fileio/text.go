@@ -0,0 +1,105 @@
+package fileio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// TextOptions describe how a text file is decoded before it is parsed.
+type TextOptions struct {
+	// Encoding of the file, e.g. utf-8 (default), windows-1252, iso-8859-1
+	// or utf-16.
+	Encoding string `yaml:"encoding"`
+	// StripBOM removes a leading byte order mark, enabled by default.
+	StripBOM *bool `yaml:"strip_bom"`
+	// SkipLines drops preamble lines before the content is parsed.
+	SkipLines int `yaml:"skip_lines"`
+}
+
+// Encoding returns the decoder for the named character set.
+func Encoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf-8", "utf8":
+		return unicode.UTF8, nil
+	case "windows-1252", "cp1252", "win1252":
+		return charmap.Windows1252, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return charmap.ISO8859_1, nil
+	case "iso-8859-15", "latin9", "latin-9":
+		return charmap.ISO8859_15, nil
+	case "cp850", "ibm850":
+		return charmap.CodePage850, nil
+	case "utf-16":
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return enc, nil
+}
+
+// CharsetReader decodes input of the named character set to UTF-8. It is
+// used as xml.Decoder.CharsetReader for the encoding of the XML declaration.
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := Encoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// NewTextReader decodes r to UTF-8, strips the byte order mark and skips the
+// preamble lines according to opts.
+func NewTextReader(r io.Reader, opts TextOptions) (*bufio.Reader, error) {
+	enc, err := Encoding(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(transform.NewReader(r, enc.NewDecoder()))
+
+	if opts.StripBOM == nil || *opts.StripBOM {
+		if err := stripBOM(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < opts.SkipLines; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+
+	return reader, nil
+}
+
+func stripBOM(reader *bufio.Reader) error {
+	r, _, err := reader.ReadRune()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r != '\uFEFF' {
+		return reader.UnreadRune()
+	}
+	return nil
+}
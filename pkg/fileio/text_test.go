@@ -0,0 +1,25 @@
+package fileio
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestCharsetReader(t *testing.T) {
+	input := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><name>K\xfchlraum</name>"
+	decoder := xml.NewDecoder(strings.NewReader(input))
+	decoder.CharsetReader = CharsetReader
+
+	var name string
+	if err := decoder.Decode(&name); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if name != "Kühlraum" {
+		t.Errorf("name = %q, want %q", name, "Kühlraum")
+	}
+
+	if _, err := CharsetReader("klingon", strings.NewReader("")); err == nil {
+		t.Error("CharsetReader(klingon) expected error")
+	}
+}
@@ -0,0 +1,120 @@
+package fileio
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Talk-Point/databridge/pkg/objstore"
+)
+
+// OutputCompression returns the compression of an output file, auto (or
+// empty) selects gzip for paths ending in .gz. Only none and gzip are
+// supported for writing.
+func OutputCompression(path, compression string) (string, error) {
+	switch compression {
+	case "", CompressionAuto:
+		if strings.HasSuffix(strings.ToLower(path), ".gz") {
+			return CompressionGzip, nil
+		}
+		return CompressionNone, nil
+	case CompressionNone, CompressionGzip:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unsupported output compression: %s", compression)
+	}
+}
+
+// Output is a file being written. Close publishes the complete file, Abort
+// discards it and keeps an existing file untouched.
+type Output interface {
+	Write(p []byte) (int, error)
+	Close() error
+	Abort() error
+}
+
+// Create returns the output of path, a local file written atomically or,
+// with a store, an object uploaded as stream. With gzip compression the
+// written data is compressed.
+func Create(path, compression string, store *objstore.Store) (Output, error) {
+	var out Output
+	if store != nil {
+		out = store.NewWriter(strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	} else {
+		file, err := CreateAtomic(path)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+	if compression == CompressionGzip {
+		return &gzipOutput{Output: out, gz: gzip.NewWriter(out)}, nil
+	}
+	return out, nil
+}
+
+type gzipOutput struct {
+	Output
+	gz *gzip.Writer
+}
+
+func (o *gzipOutput) Write(p []byte) (int, error) {
+	return o.gz.Write(p)
+}
+
+func (o *gzipOutput) Close() error {
+	if err := o.gz.Close(); err != nil {
+		o.Output.Abort()
+		return err
+	}
+	return o.Output.Close()
+}
+
+// AtomicFile writes to a temporary file next to the target, which replaces
+// the target on Close. Readers never see a partially written file.
+type AtomicFile struct {
+	path string
+	file *os.File
+}
+
+// CreateAtomic creates the temporary file for path and the missing parent
+// directories.
+func CreateAtomic(path string) (*AtomicFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{path: path, file: file}, nil
+}
+
+func (f *AtomicFile) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+// Close syncs the data and renames the temporary file to the target.
+func (f *AtomicFile) Close() error {
+	if err := f.file.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.file.Close(); err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+	if err := os.Chmod(f.file.Name(), 0o644); err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+	return os.Rename(f.file.Name(), f.path)
+}
+
+// Abort removes the temporary file and keeps the target untouched.
+func (f *AtomicFile) Abort() error {
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
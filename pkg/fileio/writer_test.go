@@ -0,0 +1,64 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputCompression(t *testing.T) {
+	tests := []struct {
+		path        string
+		compression string
+		want        string
+		wantErr     bool
+	}{
+		{"out.csv", "", CompressionNone, false},
+		{"out.csv.GZ", CompressionAuto, CompressionGzip, false},
+		{"out.csv", CompressionGzip, CompressionGzip, false},
+		{"out.csv.gz", CompressionNone, CompressionNone, false},
+		{"out.csv", "zip", "", true},
+	}
+	for _, tt := range tests {
+		got, err := OutputCompression(tt.path, tt.compression)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("OutputCompression(%q, %q) = %q, %v", tt.path, tt.compression, got, err)
+		}
+	}
+}
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "out.csv")
+
+	f, err := CreateAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("a,b\n"))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("target exists before Close")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a,b\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	// an aborted write keeps the previous file
+	f, err = CreateAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("partial"))
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a,b\n" {
+		t.Errorf("content after Abort = %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("expected only the target, got %d entries", len(entries))
+	}
+}
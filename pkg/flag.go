@@ -2,10 +2,32 @@ package pkg
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
+// VarsFlag collects repeated `-var key=value` flags.
+type VarsFlag map[string]string
+
+func (v VarsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v VarsFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid var %q, expected key=value", value)
+	}
+	v[key] = val
+	return nil
+}
+
 type TimePartitionParams struct {
 	LogLevel   string
 	ConfigPath string
@@ -19,6 +41,7 @@ type TimePartitionParams struct {
 	EndTime    time.Time
 	Kestra     bool
 	FilePath   string
+	Vars       VarsFlag
 }
 
 func NewTimePartitionParams() *TimePartitionParams {
@@ -27,6 +50,7 @@ func NewTimePartitionParams() *TimePartitionParams {
 		Kestra:    false,
 		LogLevel:  "info",
 		RunSchema: false,
+		Vars:      VarsFlag{},
 	}
 }
 
@@ -41,6 +65,7 @@ func (p *TimePartitionParams) ParseFlags() error {
 	flag.StringVar(&p.Interval, "interval", "", "Interval duration (e.g., 30m for 30 minutes)")
 	flag.StringVar(&p.FilePath, "file-path", "", "Path to file")
 	flag.BoolVar(&p.Kestra, "kestra", false, "Output kestra metrics")
+	flag.Var(p.Vars, "var", "Template variable key=value, can be repeated")
 
 	// Parse CLI flags
 	flag.Parse()
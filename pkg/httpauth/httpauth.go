@@ -0,0 +1,332 @@
+package httpauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+)
+
+const (
+	None   = "none"
+	APIKey = "api_key"
+	Bearer = "bearer"
+	Basic  = "basic"
+	OAuth2 = "oauth2"
+	MTLS   = "mtls"
+)
+
+// Secret is a value read from the config, an environment variable or a file.
+//
+//	token:
+//	  env: API_TOKEN
+//	password:
+//	  file: /run/secrets/erp_password
+type Secret struct {
+	Value string `yaml:"value"`
+	Env   string `yaml:"env"`
+	File  string `yaml:"file"`
+}
+
+// Resolve returns the secret, preferring the environment over the file over
+// the inline value.
+func (s Secret) Resolve() (string, error) {
+	if s.Env != "" {
+		value := os.Getenv(s.Env)
+		if value == "" {
+			return "", fmt.Errorf("%s environment variable is required", s.Env)
+		}
+		return value, nil
+	}
+	if s.File != "" {
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return s.Value, nil
+}
+
+func (s Secret) isSet() bool {
+	return s.Value != "" || s.Env != "" || s.File != ""
+}
+
+// Config is the `auth:` block of HTTP based plugins.
+//
+//	auth:
+//	  type: api_key
+//	  header: X-API-KEY
+//	  key:
+//	    env: API_TOKEN
+//
+//	auth:
+//	  type: oauth2
+//	  token_url: https://login.example.com/oauth/token
+//	  client_id:
+//	    env: CLIENT_ID
+//	  client_secret:
+//	    file: /run/secrets/client_secret
+//	  scopes: [erp.read]
+//
+// Client certificates can be combined with every type (or used alone with
+// type mtls) via client_cert, client_key and ca_cert.
+type Config struct {
+	Type         string   `yaml:"type"`
+	Header       string   `yaml:"header"`
+	Key          Secret   `yaml:"key"`
+	Token        Secret   `yaml:"token"`
+	Username     Secret   `yaml:"username"`
+	Password     Secret   `yaml:"password"`
+	TokenURL     string   `yaml:"token_url"`
+	ClientID     Secret   `yaml:"client_id"`
+	ClientSecret Secret   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+	Audience     string   `yaml:"audience"`
+	ClientCert   Secret   `yaml:"client_cert"`
+	ClientKey    Secret   `yaml:"client_key"`
+	CACert       Secret   `yaml:"ca_cert"`
+}
+
+// Auth applies the configured credentials to outgoing requests.
+type Auth struct {
+	Type   string
+	Header string
+	Value  string
+
+	username     string
+	password     string
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	audience     string
+
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// Parse builds an Auth from the raw `auth:` block of a plugin config.
+func Parse(raw interface{}) (*Auth, error) {
+	cfg := Config{}
+	if err := config.Decode(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid auth config: %v", err)
+	}
+	return New(cfg)
+}
+
+// New resolves all secrets of the config and builds an Auth.
+func New(cfg Config) (*Auth, error) {
+	a := &Auth{Type: cfg.Type}
+	if a.Type == "" {
+		a.Type = None
+	}
+
+	var err error
+	switch a.Type {
+	case None, MTLS:
+	case APIKey:
+		a.Header = cfg.Header
+		if a.Header == "" {
+			a.Header = "X-API-KEY"
+		}
+		if a.Value, err = required("key", cfg.Key); err != nil {
+			return nil, err
+		}
+	case Bearer:
+		if a.Value, err = required("token", cfg.Token); err != nil {
+			return nil, err
+		}
+	case Basic:
+		if a.username, err = required("username", cfg.Username); err != nil {
+			return nil, err
+		}
+		if a.password, err = cfg.Password.Resolve(); err != nil {
+			return nil, err
+		}
+	case OAuth2:
+		if cfg.TokenURL == "" {
+			return nil, errors.New("auth token_url is required for oauth2")
+		}
+		a.tokenURL = cfg.TokenURL
+		a.scopes = cfg.Scopes
+		a.audience = cfg.Audience
+		if a.clientID, err = required("client_id", cfg.ClientID); err != nil {
+			return nil, err
+		}
+		if a.clientSecret, err = required("client_secret", cfg.ClientSecret); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid auth type: %s", a.Type)
+	}
+
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if a.Type == MTLS && (tlsConfig == nil || len(tlsConfig.Certificates) == 0) {
+		return nil, errors.New("auth client_cert and client_key are required for mtls")
+	}
+
+	a.client = &http.Client{}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		a.client.Transport = transport
+	}
+
+	return a, nil
+}
+
+func required(name string, secret Secret) (string, error) {
+	if !secret.isSet() {
+		return "", fmt.Errorf("auth %s is required", name)
+	}
+	value, err := secret.Resolve()
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("auth %s is empty", name)
+	}
+	return value, nil
+}
+
+// loadTLSConfig reads the PEM encoded client certificate, key and CA. The
+// secrets contain the PEM data itself when read from the environment or
+// inline, and are read from disk when given as file.
+func loadTLSConfig(cfg Config) (*tls.Config, error) {
+	if !cfg.ClientCert.isSet() && !cfg.ClientKey.isSet() && !cfg.CACert.isSet() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.ClientCert.isSet() || cfg.ClientKey.isSet() {
+		certPEM, err := required("client_cert", cfg.ClientCert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := required("client_key", cfg.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CACert.isSet() {
+		caPEM, err := cfg.CACert.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+			return nil, errors.New("invalid ca_cert")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// Client returns the HTTP client to use, configured with client
+// certificates if any.
+func (a *Auth) Client() *http.Client {
+	if a.client == nil {
+		return http.DefaultClient
+	}
+	return a.client
+}
+
+// Apply adds the credentials to the request.
+func (a *Auth) Apply(req *http.Request) error {
+	switch a.Type {
+	case APIKey:
+		req.Header.Set(a.Header, a.Value)
+	case Bearer:
+		req.Header.Set("Authorization", "Bearer "+a.Value)
+	case Basic:
+		req.SetBasicAuth(a.username, a.password)
+	case OAuth2:
+		token, err := a.accessToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// accessToken returns the cached OAuth2 token or requests a new one using
+// the client credentials grant.
+func (a *Auth) accessToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	if a.audience != "" {
+		form.Set("audience", a.audience)
+	}
+
+	req, err := http.NewRequest("POST", a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to fetch oauth2 token: %s", string(bodyBytes))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("oauth2 token response without access_token")
+	}
+
+	a.token = result.AccessToken
+	// refresh a little early so the token does not expire in flight
+	expiresIn := time.Duration(result.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+	a.tokenExpiry = time.Now().Add(expiresIn - expiresIn/10)
+
+	return a.token, nil
+}
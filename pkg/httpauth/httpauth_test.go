@@ -0,0 +1,147 @@
+package httpauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	t.Setenv("TEST_API_TOKEN", "env-token")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config map[interface{}]interface{}
+		header string
+		want   string
+	}{
+		{
+			name: "api key from env with default header",
+			config: map[interface{}]interface{}{
+				"type": "api_key",
+				"key":  map[interface{}]interface{}{"env": "TEST_API_TOKEN"},
+			},
+			header: "X-API-KEY",
+			want:   "env-token",
+		},
+		{
+			name: "api key with custom header",
+			config: map[interface{}]interface{}{
+				"type":   "api_key",
+				"header": "X-Gateway-Key",
+				"key":    map[interface{}]interface{}{"value": "inline"},
+			},
+			header: "X-Gateway-Key",
+			want:   "inline",
+		},
+		{
+			name: "bearer from file",
+			config: map[interface{}]interface{}{
+				"type":  "bearer",
+				"token": map[interface{}]interface{}{"file": tokenFile},
+			},
+			header: "Authorization",
+			want:   "Bearer file-token",
+		},
+		{
+			name: "basic",
+			config: map[interface{}]interface{}{
+				"type":     "basic",
+				"username": map[interface{}]interface{}{"value": "user"},
+				"password": map[interface{}]interface{}{"value": "pass"},
+			},
+			header: "Authorization",
+			want:   "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := Parse(tt.config)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			req, _ := http.NewRequest("GET", "http://localhost", nil)
+			if err := auth.Apply(req); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("header %s = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[interface{}]interface{}
+	}{
+		{"unknown type", map[interface{}]interface{}{"type": "kerberos"}},
+		{"api key without key", map[interface{}]interface{}{"type": "api_key"}},
+		{"missing env", map[interface{}]interface{}{
+			"type":  "bearer",
+			"token": map[interface{}]interface{}{"env": "TEST_NOT_SET_TOKEN"},
+		}},
+		{"oauth2 without token url", map[interface{}]interface{}{"type": "oauth2"}},
+		{"mtls without certificate", map[interface{}]interface{}{"type": "mtls"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestOAuth2TokenCaching(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" {
+			t.Errorf("unexpected grant_type %s", r.Form.Get("grant_type"))
+		}
+		if id, secret, _ := r.BasicAuth(); id != "id" || secret != "secret" {
+			t.Errorf("unexpected client credentials %s:%s", id, secret)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "abc",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	auth, err := Parse(map[interface{}]interface{}{
+		"type":          "oauth2",
+		"token_url":     server.URL,
+		"client_id":     map[interface{}]interface{}{"value": "id"},
+		"client_secret": map[interface{}]interface{}{"value": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost", nil)
+		if err := auth.Apply(req); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer abc" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer abc")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the token to be requested once, got %d", requests)
+	}
+}
@@ -0,0 +1,196 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed JSONPath-like selector. Supported are dotted keys, array
+// indexes and wildcards, with or without the leading `$`:
+//
+//	$.data.items[*]
+//	data.items
+//	orders[0].lines[*].sku
+//	meta["next-page"]
+type Path []segment
+
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Parse parses a selector. The empty selector and `$` select the document
+// itself.
+func Parse(selector string) (Path, error) {
+	s := strings.TrimSpace(selector)
+	s = strings.TrimPrefix(s, "$")
+
+	var path Path
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				path = append(path, segment{wildcard: true})
+				s = s[1:]
+				continue
+			}
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid selector %q: empty key", selector)
+			}
+			path = append(path, segment{key: s[:end]})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid selector %q: missing ]", selector)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, segment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				path = append(path, segment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector %q: invalid index %q", selector, inner)
+				}
+				path = append(path, segment{index: index, isIndex: true})
+			}
+		default:
+			// allow a leading key without dot, e.g. "data.items"
+			s = "." + s
+		}
+	}
+	return path, nil
+}
+
+// Get returns the single value the path points to.
+func (p Path) Get(document interface{}) (interface{}, bool) {
+	current := document
+	for _, seg := range p {
+		switch {
+		case seg.wildcard:
+			// wildcards select several values, see Select
+			return nil, false
+		case seg.isIndex:
+			items, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			index := seg.index
+			if index < 0 {
+				index += len(items)
+			}
+			if index < 0 || index >= len(items) {
+				return nil, false
+			}
+			current = items[index]
+		default:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			current, ok = object[seg.key]
+			if !ok {
+				return nil, false
+			}
+		}
+	}
+	return current, true
+}
+
+// Select returns all values the path points to. Wildcards expand arrays and
+// objects; when the final value is an array and the path ends without a
+// wildcard, its elements are returned so `data.items` and `data.items[*]`
+// select the same records.
+func (p Path) Select(document interface{}) []interface{} {
+	values := p.selectFrom(document)
+	if len(p) == 0 || !p[len(p)-1].wildcard {
+		if len(values) == 1 {
+			if items, ok := values[0].([]interface{}); ok {
+				return items
+			}
+		}
+	}
+	return values
+}
+
+func (p Path) selectFrom(current interface{}) []interface{} {
+	for i, seg := range p {
+		if !seg.wildcard {
+			value, ok := p[i : i+1].Get(current)
+			if !ok {
+				return nil
+			}
+			current = value
+			continue
+		}
+
+		var children []interface{}
+		switch v := current.(type) {
+		case []interface{}:
+			children = v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				children = append(children, v[key])
+			}
+		default:
+			return nil
+		}
+
+		var result []interface{}
+		for _, child := range children {
+			result = append(result, p[i+1:].selectFrom(child)...)
+		}
+		return result
+	}
+	return []interface{}{current}
+}
+
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range p {
+		switch {
+		case seg.wildcard:
+			b.WriteString("[*]")
+		case seg.isIndex:
+			fmt.Fprintf(&b, "[%d]", seg.index)
+		default:
+			b.WriteString(".")
+			b.WriteString(seg.key)
+		}
+	}
+	return b.String()
+}
+
+// ExpectDelim reads the next token of a streaming decoder and fails unless
+// it is delim, e.g. the `[` of an array whose elements are decoded one at a
+// time.
+func ExpectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected JSON: expected %v, got %v", delim, token)
+	}
+	return nil
+}
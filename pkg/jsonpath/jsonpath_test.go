@@ -0,0 +1,86 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const document = `{
+	"data": {
+		"items": [
+			{"id": 1, "lines": [{"sku": "a"}, {"sku": "b"}]},
+			{"id": 2, "lines": [{"sku": "c"}]}
+		],
+		"next-page": "abc"
+	}
+}`
+
+func TestSelect(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		selector string
+		want     []interface{}
+	}{
+		{"$.data.items[*].id", []interface{}{1.0, 2.0}},
+		{"data.items[*].lines[*].sku", []interface{}{"a", "b", "c"}},
+		{"data.items[1].id", []interface{}{2.0}},
+		{"data.items[-1].lines[0].sku", []interface{}{"c"}},
+		{`data["next-page"]`, []interface{}{"abc"}},
+		{"data.missing", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			path, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := path.Select(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// selecting an array without wildcard returns its elements
+	for _, selector := range []string{"data.items", "$.data.items[*]"} {
+		if got := mustSelect(t, selector, doc); len(got) != 2 {
+			t.Errorf("Select(%s) returned %d items, want 2", selector, len(got))
+		}
+	}
+}
+
+func mustSelect(t *testing.T, selector string, doc interface{}) []interface{} {
+	path, err := Parse(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.Select(doc)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, selector := range []string{"data.[", "data[abc]", "data..items"} {
+		if _, err := Parse(selector); err == nil {
+			t.Errorf("Parse(%s) expected error", selector)
+		}
+	}
+}
+
+func TestExpectDelim(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`[{"a": 1}]`))
+	if err := ExpectDelim(decoder, '['); err != nil {
+		t.Fatalf("ExpectDelim([) error = %v", err)
+	}
+	if err := ExpectDelim(decoder, '['); err == nil {
+		t.Error("ExpectDelim([) on an object expected error")
+	}
+
+	if err := ExpectDelim(json.NewDecoder(strings.NewReader(``)), '{'); err == nil {
+		t.Error("ExpectDelim({) on empty input expected error")
+	}
+}
@@ -0,0 +1,227 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+)
+
+// Field is the source field of a model column, either by name or by its 1
+// based position.
+type Field struct {
+	Name  string
+	Index int
+}
+
+func (f Field) String() string {
+	if f.Index > 0 {
+		return fmt.Sprintf("#%d", f.Index)
+	}
+	return f.Name
+}
+
+// Mapping maps source fields onto model columns. Columns without an entry
+// are looked up by their own name.
+//
+//	mapping:
+//	  case_insensitive: true
+//	  strict: true
+//	  required: [name]
+//	  columns:
+//	    kunden_nr: Kundennummer
+//	    umsatz: 4
+type Mapping struct {
+	Columns         map[string]Field
+	CaseInsensitive bool
+	// Strict fails when a required model column cannot be found in the
+	// source. Required are the non nullable columns (unique key and
+	// datetime columns) and the columns listed in Required.
+	Strict   bool
+	Required []string
+}
+
+type mappingConfig struct {
+	CaseInsensitive bool                   `yaml:"case_insensitive"`
+	Strict          bool                   `yaml:"strict"`
+	Required        []string               `yaml:"required"`
+	Columns         map[string]interface{} `yaml:"columns"`
+}
+
+// Parse reads the `mapping:` block of a source config. Without a block
+// columns are matched by their exact name.
+func Parse(raw interface{}, model *models.Model) (*Mapping, error) {
+	m := &Mapping{Columns: map[string]Field{}}
+	if raw == nil {
+		return m, nil
+	}
+
+	cfg := mappingConfig{}
+	if err := config.Decode(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid mapping config: %v", err)
+	}
+	m.CaseInsensitive = cfg.CaseInsensitive
+	m.Strict = cfg.Strict
+
+	for _, column := range cfg.Required {
+		if !hasColumn(model, column) {
+			return nil, fmt.Errorf("required column %s is not a model column", column)
+		}
+	}
+	m.Required = cfg.Required
+
+	for column, source := range cfg.Columns {
+		if !hasColumn(model, column) {
+			return nil, fmt.Errorf("mapping column %s is not a model column", column)
+		}
+		switch v := source.(type) {
+		case string:
+			if v == "" {
+				return nil, fmt.Errorf("mapping for column %s is empty", column)
+			}
+			m.Columns[column] = Field{Name: v}
+		case int:
+			if v < 1 {
+				return nil, fmt.Errorf("mapping index for column %s must be at least 1", column)
+			}
+			m.Columns[column] = Field{Index: v}
+		default:
+			return nil, fmt.Errorf("mapping for column %s must be a field name or index, got %v", column, source)
+		}
+	}
+
+	return m, nil
+}
+
+func hasColumn(model *models.Model, name string) bool {
+	for _, column := range model.Columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// required reports whether strict mode fails when the column is missing.
+func (m *Mapping) required(model *models.Model, column models.Column) bool {
+	if column.Type == models.DateTime {
+		return true
+	}
+	for _, name := range model.Unique {
+		if name == column.Name {
+			return true
+		}
+	}
+	for _, name := range m.Required {
+		if name == column.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the source field of a model column.
+func (m *Mapping) Field(column string) Field {
+	if field, ok := m.Columns[column]; ok {
+		return field
+	}
+	return Field{Name: column}
+}
+
+func (m *Mapping) key(name string) string {
+	if m.CaseInsensitive {
+		return strings.ToLower(strings.TrimSpace(name))
+	}
+	return name
+}
+
+// Resolve returns for every model column the 0 based position of its field
+// in the header, -1 for columns that are not present. A nil header (files
+// without header row) only resolves positional fields. In strict mode
+// missing required columns are an error.
+func (m *Mapping) Resolve(model *models.Model, header []string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		key := m.key(name)
+		if _, ok := positions[key]; !ok {
+			positions[key] = i
+		}
+	}
+
+	result := make(map[string]int, len(model.Columns))
+	var missing []string
+	for _, column := range model.Columns {
+		field := m.Field(column.Name)
+		position := -1
+		if field.Index > 0 {
+			if header == nil || field.Index <= len(header) {
+				position = field.Index - 1
+			}
+		} else if p, ok := positions[m.key(field.Name)]; ok {
+			position = p
+		}
+		if position == -1 && m.required(model, column) {
+			missing = append(missing, fmt.Sprintf("%s (%s)", column.Name, field))
+		}
+		result[column.Name] = position
+	}
+
+	if m.Strict && len(missing) > 0 {
+		return nil, fmt.Errorf("unmapped columns: %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// Record maps a keyed record (e.g. a JSON object) onto the model columns.
+// Only columns found in the record are set, in strict mode missing required
+// columns are an error. Positional fields only apply to rows, see Row.
+func (m *Mapping) Record(model *models.Model, record map[string]interface{}) (map[string]interface{}, error) {
+	lookup := record
+	if m.CaseInsensitive {
+		lookup = make(map[string]interface{}, len(record))
+		keys := make([]string, 0, len(record))
+		for key := range record {
+			keys = append(keys, key)
+		}
+		// sorted so duplicates differing only in case resolve the same way
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := lookup[m.key(key)]; !ok {
+				lookup[m.key(key)] = record[key]
+			}
+		}
+	}
+
+	result := make(map[string]interface{}, len(model.Columns))
+	var missing []string
+	for _, column := range model.Columns {
+		field := m.Field(column.Name)
+		value, ok := lookup[m.key(field.Name)]
+		if field.Index > 0 || !ok {
+			if m.required(model, column) {
+				missing = append(missing, fmt.Sprintf("%s (%s)", column.Name, field))
+			}
+			continue
+		}
+		result[column.Name] = value
+	}
+
+	if m.Strict && len(missing) > 0 {
+		return nil, fmt.Errorf("unmapped columns: %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// Row maps a row onto the model columns using the positions returned by
+// Resolve. Columns missing in the header or the row are not set.
+func Row(positions map[string]int, row []string) map[string]interface{} {
+	record := make(map[string]interface{}, len(positions))
+	for column, position := range positions {
+		if position >= 0 && position < len(row) {
+			record[column] = row[position]
+		}
+	}
+	return record
+}
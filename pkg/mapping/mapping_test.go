@@ -0,0 +1,169 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var kundenModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "kunden_nr", Type: models.String},
+		{Name: "name", Type: models.String},
+		{Name: "umsatz", Type: models.Float},
+	},
+	Unique: []string{"kunden_nr"},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     interface{}
+		wantErr bool
+	}{
+		{"no mapping", nil, false},
+		{"name and index", map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"kunden_nr": "Kundennummer", "umsatz": 3},
+		}, false},
+		{"unknown column", map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"kunde": "Kundennummer"},
+		}, true},
+		{"invalid index", map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"umsatz": 0},
+		}, true},
+		{"invalid value", map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"umsatz": 1.5},
+		}, true},
+		{"required", map[interface{}]interface{}{
+			"required": []interface{}{"name"},
+		}, false},
+		{"unknown required column", map[interface{}]interface{}{
+			"required": []interface{}{"kunde"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.raw, kundenModel)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	header := []string{"Kundennummer", "NAME", "Umsatz"}
+
+	tests := []struct {
+		name    string
+		mapping *Mapping
+		header  []string
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:    "exact names",
+			mapping: &Mapping{},
+			header:  []string{"umsatz", "kunden_nr", "name"},
+			want:    map[string]int{"kunden_nr": 1, "name": 2, "umsatz": 0},
+		},
+		{
+			name: "mapped name, case insensitive and index",
+			mapping: &Mapping{
+				Columns:         map[string]Field{"kunden_nr": {Name: "kundennummer"}, "umsatz": {Index: 3}},
+				CaseInsensitive: true,
+			},
+			header: header,
+			want:   map[string]int{"kunden_nr": 0, "name": 1, "umsatz": 2},
+		},
+		{
+			name:    "missing columns are -1",
+			mapping: &Mapping{},
+			header:  header,
+			want:    map[string]int{"kunden_nr": -1, "name": -1, "umsatz": -1},
+		},
+		{
+			name:    "strict fails on missing columns",
+			mapping: &Mapping{Strict: true, CaseInsensitive: true},
+			header:  header,
+			wantErr: true,
+		},
+		{
+			name:    "strict ignores missing nullable columns",
+			mapping: &Mapping{Strict: true},
+			header:  []string{"kunden_nr"},
+			want:    map[string]int{"kunden_nr": 0, "name": -1, "umsatz": -1},
+		},
+		{
+			name:    "strict fails on missing required columns",
+			mapping: &Mapping{Strict: true, Required: []string{"name"}},
+			header:  []string{"kunden_nr"},
+			wantErr: true,
+		},
+		{
+			name: "positions without header",
+			mapping: &Mapping{
+				Columns: map[string]Field{"kunden_nr": {Index: 1}, "name": {Index: 2}, "umsatz": {Index: 7}},
+				Strict:  true,
+			},
+			want: map[string]int{"kunden_nr": 0, "name": 1, "umsatz": 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.Resolve(kundenModel, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	positions := map[string]int{"kunden_nr": 0, "name": -1, "umsatz": 5}
+	if got := Row(positions, []string{"K1", "x"}); !reflect.DeepEqual(got, map[string]interface{}{"kunden_nr": "K1"}) {
+		t.Errorf("Row() = %v", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	record := map[string]interface{}{"Kundennummer": "K1", "Name": "Muster", "UMSATZ": 1.5}
+
+	m := &Mapping{
+		Columns:         map[string]Field{"kunden_nr": {Name: "KUNDENNUMMER"}},
+		CaseInsensitive: true,
+		Strict:          true,
+	}
+	got, err := m.Record(kundenModel, record)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	want := map[string]interface{}{"kunden_nr": "K1", "name": "Muster", "umsatz": 1.5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Record() = %v, want %v", got, want)
+	}
+
+	m.CaseInsensitive = false
+	if _, err := m.Record(kundenModel, record); err == nil {
+		t.Error("expected strict error for case sensitive lookup")
+	}
+
+	// an unmapped nullable datetime passes, a missing datetime fails
+	timeModel := &models.Model{Columns: []models.Column{
+		{Name: "kunden_nr", Type: models.String},
+		{Name: "geloescht_am", Type: models.DateTimeNullable},
+	}}
+	m = &Mapping{Strict: true}
+	got, err = m.Record(timeModel, map[string]interface{}{"kunden_nr": "K1"})
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"kunden_nr": "K1"}) {
+		t.Errorf("Record() = %v, %v", got, err)
+	}
+	timeModel.Columns[1].Type = models.DateTime
+	if _, err := m.Record(timeModel, map[string]interface{}{"kunden_nr": "K1"}); err == nil {
+		t.Error("expected strict error for missing datetime column")
+	}
+}
@@ -0,0 +1,403 @@
+package objstore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+const (
+	SSENone     = "none"
+	SSES3       = "s3"
+	SSEKMS      = "kms"
+	SSECustomer = "customer"
+)
+
+// Options are the `s3:` block of file sources and destinations.
+//
+//	s3:
+//	  endpoint: http://minio:9000
+//	  bucket: partner-drops
+//	  access_key:
+//	    env: S3_ACCESS_KEY
+//	  secret_key:
+//	    env: S3_SECRET_KEY
+type Options struct {
+	// Endpoint is the host or URL of the S3 API, defaults to AWS. A http://
+	// URL disables TLS.
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	Bucket   string `yaml:"bucket"`
+	// AccessKey and SecretKey are static credentials. Without them the
+	// AWS environment variables, the shared credentials file and the
+	// instance role are used.
+	AccessKey    httpauth.Secret `yaml:"access_key"`
+	SecretKey    httpauth.Secret `yaml:"secret_key"`
+	SessionToken httpauth.Secret `yaml:"session_token"`
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// most MinIO setups need it.
+	PathStyle bool `yaml:"path_style"`
+	// PartSize is the size of the multipart upload parts in MiB, defaults
+	// to 16.
+	PartSize int        `yaml:"part_size"`
+	SSE      SSEOptions `yaml:"sse"`
+}
+
+// SSEOptions select the server-side encryption of written objects.
+type SSEOptions struct {
+	// Type is none (default), s3, kms or customer.
+	Type     string `yaml:"type"`
+	KMSKeyID string `yaml:"kms_key_id"`
+	// Key is the base64 encoded 256 bit key of customer encryption, it is
+	// needed to read the objects again.
+	Key httpauth.Secret `yaml:"key"`
+}
+
+func (o SSEOptions) serverSide() (encrypt.ServerSide, error) {
+	switch o.Type {
+	case "", SSENone:
+		return nil, nil
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	case SSEKMS:
+		if o.KMSKeyID == "" {
+			return nil, errors.New("sse kms_key_id is required for kms")
+		}
+		return encrypt.NewSSEKMS(o.KMSKeyID, nil)
+	case SSECustomer:
+		value, err := o.Key.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("sse key must be base64 encoded: %v", err)
+		}
+		return encrypt.NewSSEC(key)
+	default:
+		return nil, fmt.Errorf("invalid sse type: %s", o.Type)
+	}
+}
+
+// Object is an object of a listing.
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// Store reads and writes the objects of one bucket.
+type Store struct {
+	Bucket   string
+	PartSize uint64
+
+	client *minio.Client
+	sse    encrypt.ServerSide
+}
+
+func New(opts Options) (*Store, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+
+	endpoint, secure, err := parseEndpoint(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := opts.credentials()
+	if err != nil {
+		return nil, err
+	}
+	lookup := minio.BucketLookupAuto
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{Bucket: opts.Bucket, PartSize: 16 << 20, client: client}
+	if opts.PartSize > 0 {
+		// S3 rejects parts below 5 MiB except the last one
+		if opts.PartSize < 5 {
+			return nil, errors.New("s3 part_size must be at least 5 MiB")
+		}
+		s.PartSize = uint64(opts.PartSize) << 20
+	}
+	if s.sse, err = opts.SSE.serverSide(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseEndpoint(endpoint string) (string, bool, error) {
+	if endpoint == "" {
+		return "s3.amazonaws.com", true, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, true, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid s3 endpoint: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return "", false, fmt.Errorf("invalid s3 endpoint scheme: %s", u.Scheme)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return "", false, errors.New("s3 endpoint must not have a path")
+	}
+	return u.Host, u.Scheme == "https", nil
+}
+
+func (o Options) credentials() (*credentials.Credentials, error) {
+	accessKey, err := o.AccessKey.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := o.SecretKey.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	sessionToken, err := o.SessionToken.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if accessKey != "" || secretKey != "" {
+		return credentials.NewStaticV4(accessKey, secretKey, sessionToken), nil
+	}
+	return credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	}), nil
+}
+
+// List returns the objects below prefix in key order. Without recursive
+// only the objects directly below the prefix are listed, keys ending in a
+// slash (folder markers) are left out.
+func (s *Store) List(prefix string, recursive bool) ([]Object, error) {
+	var objects []Object
+	listing := s.client.ListObjects(context.Background(), s.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: recursive,
+	})
+	for info := range listing {
+		if info.Err != nil {
+			return nil, fmt.Errorf("error listing s3://%s/%s: %v", s.Bucket, prefix, info.Err)
+		}
+		if strings.HasSuffix(info.Key, "/") {
+			continue
+		}
+		objects = append(objects, Object{
+			Key:          info.Key,
+			Size:         info.Size,
+			ETag:         info.ETag,
+			LastModified: info.LastModified,
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// HasPrefix reports whether any object or folder marker starts with prefix.
+func (s *Store) HasPrefix(prefix string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for info := range s.client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		if info.Err != nil {
+			return false, fmt.Errorf("error listing s3://%s/%s: %v", s.Bucket, prefix, info.Err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Stat returns the object, nil if it does not exist.
+func (s *Store) Stat(key string) (*Object, error) {
+	info, err := s.client.StatObject(context.Background(), s.Bucket, key, minio.StatObjectOptions{
+		ServerSideEncryption: s.readSSE(),
+	})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == 404 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &Object{
+		Key:          key,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// Exists reports whether the object exists.
+func (s *Store) Exists(key string) (bool, error) {
+	object, err := s.Stat(key)
+	return object != nil, err
+}
+
+// Get returns the content of the object as stream.
+func (s *Store) Get(key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.Bucket, key, minio.GetObjectOptions{
+		ServerSideEncryption: s.readSSE(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, stat fails for missing objects
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("s3://%s/%s: %v", s.Bucket, key, err)
+	}
+	return object, nil
+}
+
+// Download writes the content of the object to w.
+func (s *Store) Download(key string, w io.Writer) (int64, error) {
+	object, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	defer object.Close()
+	return io.Copy(w, object)
+}
+
+// Upload stores the content of r as object. Streams of unknown size (-1)
+// and large streams are uploaded in parts of PartSize, the object only
+// appears once the upload is complete.
+func (s *Store) Upload(key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(context.Background(), s.Bucket, key, r, size, minio.PutObjectOptions{
+		PartSize:             s.PartSize,
+		ServerSideEncryption: s.sse,
+		ContentType:          contentType(key),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading s3://%s/%s: %v", s.Bucket, key, err)
+	}
+	return nil
+}
+
+func contentType(key string) string {
+	name := strings.TrimSuffix(strings.ToLower(key), ".gz")
+	if name != strings.ToLower(key) {
+		return "application/gzip"
+	}
+	switch path.Ext(name) {
+	case ".csv":
+		return "text/csv"
+	case ".ndjson":
+		return "application/x-ndjson"
+	case ".json":
+		return "application/json"
+	case ".parquet":
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Remove deletes the object.
+func (s *Store) Remove(key string) error {
+	return s.client.RemoveObject(context.Background(), s.Bucket, key, minio.RemoveObjectOptions{})
+}
+
+// Move copies the object below prefix and removes the original. An
+// existing object with the same name is kept and the moved object gets a
+// timestamp suffix. It returns the new key.
+func (s *Store) Move(key, prefix string) (string, error) {
+	target := path.Join(prefix, path.Base(key))
+	exists, err := s.Exists(target)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		target = fmt.Sprintf("%s.%s", target, time.Now().Format("20060102T150405"))
+	}
+
+	src := minio.CopySrcOptions{Bucket: s.Bucket, Object: key}
+	if s.sse != nil && s.sse.Type() == encrypt.SSEC {
+		src.Encryption = s.sse
+	}
+	_, err = s.client.CopyObject(context.Background(), minio.CopyDestOptions{
+		Bucket:     s.Bucket,
+		Object:     target,
+		Encryption: s.sse,
+	}, src)
+	if err != nil {
+		return "", fmt.Errorf("error copying s3://%s/%s: %v", s.Bucket, key, err)
+	}
+	return target, s.Remove(key)
+}
+
+// readSSE returns the encryption needed to read objects, only customer
+// keys have to be sent again.
+func (s *Store) readSSE() encrypt.ServerSide {
+	if s.sse != nil && s.sse.Type() == encrypt.SSEC {
+		return s.sse
+	}
+	return nil
+}
+
+// URL returns the s3:// URL of the key for logs.
+func (s *Store) URL(key string) string {
+	return "s3://" + s.Bucket + "/" + strings.TrimPrefix(key, "/")
+}
+
+// Writer uploads everything written to it as one object.
+type Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// NewWriter starts a streaming upload of key. The object is created by
+// Close, Abort cancels the upload.
+func (s *Store) NewWriter(key string) *Writer {
+	pr, pw := io.Pipe()
+	w := &Writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := s.Upload(key, pr, -1)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close completes the upload.
+func (w *Writer) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Abort cancels the upload, uploaded parts are discarded.
+func (w *Writer) Abort() error {
+	w.pw.CloseWithError(errors.New("upload aborted"))
+	<-w.done
+	return nil
+}
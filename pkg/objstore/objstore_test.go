@@ -0,0 +1,171 @@
+package objstore
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/objstore/s3test"
+)
+
+func newStore(t *testing.T, server *s3test.Server, sse SSEOptions) *Store {
+	t.Helper()
+	store, err := New(Options{
+		Endpoint:  server.URL,
+		Region:    "eu-central-1",
+		Bucket:    "drops",
+		AccessKey: httpauth.Secret{Value: "minio"},
+		SecretKey: httpauth.Secret{Value: "minio123"},
+		PathStyle: true,
+		PartSize:  5,
+		SSE:       sse,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestList(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	for _, key := range []string{"in/b.csv", "in/a.csv", "in/", "in/sub/c.csv", "other/d.csv"} {
+		server.Put("drops", key, []byte(key))
+	}
+	store := newStore(t, server, SSEOptions{})
+
+	tests := []struct {
+		prefix    string
+		recursive bool
+		want      []string
+	}{
+		{"in/", false, []string{"in/a.csv", "in/b.csv"}},
+		{"in/", true, []string{"in/a.csv", "in/b.csv", "in/sub/c.csv"}},
+		{"in/a", false, []string{"in/a.csv"}},
+		{"missing/", true, nil},
+	}
+	for _, tt := range tests {
+		objects, err := store.List(tt.prefix, tt.recursive)
+		if err != nil {
+			t.Fatalf("List(%q) error = %v", tt.prefix, err)
+		}
+		var keys []string
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+		}
+		if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("List(%q, %v) = %v, want %v", tt.prefix, tt.recursive, keys, tt.want)
+		}
+	}
+
+	for prefix, want := range map[string]bool{"in/": true, "in/sub/": true, "other/": true, "missing/": false} {
+		if got, err := store.HasPrefix(prefix); err != nil || got != want {
+			t.Errorf("HasPrefix(%q) = %v, %v, want %v", prefix, got, err, want)
+		}
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	store := newStore(t, server, SSEOptions{Type: SSES3})
+
+	// 12 MiB are uploaded in three parts of 5 MiB
+	data := bytes.Repeat([]byte("0123456789abcdef"), 12<<16)
+	if err := store.Upload("out/big.csv", bytes.NewReader(data), -1); err != nil {
+		t.Fatal(err)
+	}
+	if server.Parts != 3 {
+		t.Errorf("parts = %d, want 3", server.Parts)
+	}
+	object := server.Get("drops", "out/big.csv")
+	if object == nil {
+		t.Fatal("object not stored")
+	}
+	if got := object.Header.Get("X-Amz-Server-Side-Encryption"); got != "AES256" {
+		t.Errorf("sse header = %q", got)
+	}
+	if got := object.Header.Get("Content-Type"); got != "text/csv" {
+		t.Errorf("content type = %q", got)
+	}
+
+	var buf bytes.Buffer
+	n, err := store.Download("out/big.csv", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("downloaded %d bytes, want %d", n, len(data))
+	}
+
+	if _, err := store.Get("out/missing.csv"); err == nil {
+		t.Error("expected error for missing object")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	store := newStore(t, server, SSEOptions{})
+
+	w := store.NewWriter("out/a.ndjson")
+	io.WriteString(w, `{"a":1}`+"\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if object := server.Get("drops", "out/a.ndjson"); object == nil || string(object.Data) != `{"a":1}`+"\n" {
+		t.Errorf("object = %+v", object)
+	}
+
+	w = store.NewWriter("out/b.ndjson")
+	io.WriteString(w, "partial")
+	w.Abort()
+	if server.Get("drops", "out/b.ndjson") != nil {
+		t.Error("aborted upload created the object")
+	}
+}
+
+func TestMove(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	store := newStore(t, server, SSEOptions{Type: SSEKMS, KMSKeyID: "arn:aws:kms:key"})
+	server.Put("drops", "in/a.csv", []byte("new"))
+	server.Put("drops", "archive/a.csv", []byte("old"))
+
+	target, err := store.Move("in/a.csv", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(target, "archive/a.csv.") {
+		t.Errorf("target = %s, want timestamp suffix", target)
+	}
+	if server.Get("drops", "in/a.csv") != nil {
+		t.Error("source was not removed")
+	}
+	object := server.Get("drops", target)
+	if object == nil || string(object.Data) != "new" {
+		t.Fatalf("moved object = %+v", object)
+	}
+	if got := object.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != "arn:aws:kms:key" {
+		t.Errorf("kms key header = %q", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []Options{
+		{},
+		{Bucket: "drops", Endpoint: "ftp://minio"},
+		{Bucket: "drops", Endpoint: "http://minio:9000/path"},
+		{Bucket: "drops", PartSize: 1},
+		{Bucket: "drops", SSE: SSEOptions{Type: "des"}},
+		{Bucket: "drops", SSE: SSEOptions{Type: SSEKMS}},
+		{Bucket: "drops", SSE: SSEOptions{Type: SSECustomer, Key: httpauth.Secret{Value: "c2hvcnQ="}}},
+	}
+	for _, opts := range tests {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) expected error", opts)
+		}
+	}
+}
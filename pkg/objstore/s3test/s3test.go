@@ -0,0 +1,276 @@
+// Package s3test provides an in-memory S3 server for tests. It implements
+// the subset of the API used by objstore: listing, get, put, multipart
+// uploads, copy and delete with path style addressing.
+package s3test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is a stored object with the request headers of its upload.
+type Object struct {
+	Data   []byte
+	Header http.Header
+}
+
+// Server is the fake S3 endpoint, Objects is keyed by "bucket/key".
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	Objects map[string]*Object
+	// Parts counts the uploaded multipart parts.
+	Parts   int
+	uploads map[string]*upload
+	nextID  int
+}
+
+type upload struct {
+	header http.Header
+	parts  map[int][]byte
+}
+
+func NewServer() *Server {
+	s := &Server{Objects: map[string]*Object{}, uploads: map[string]*upload{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Put stores an object directly.
+func (s *Server) Put(bucket, key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Objects[bucket+"/"+key] = &Object{Data: data, Header: http.Header{}}
+}
+
+// Get returns a stored object or nil.
+func (s *Server) Get(bucket, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Objects[bucket+"/"+key]
+}
+
+// Keys returns the stored keys of a bucket in order.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for name := range s.Objects {
+		if key, ok := strings.CutPrefix(name, bucket+"/"); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	name := bucket + "/" + key
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, bucket, query.Get("prefix"), query.Get("delimiter"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.uploads[id] = &upload{header: r.Header.Clone(), parts: map[int][]byte{}}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		u, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		u.parts[number] = data
+		s.Parts++
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		u, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(u.parts))
+		for number := range u.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, number := range numbers {
+			data = append(data, u.parts[number]...)
+		}
+		s.Objects[name] = &Object{Data: data, Header: u.header}
+		delete(s.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: etag(data)})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, ok := s.Objects[strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		s.Objects[name] = &Object{Data: source.Data, Header: r.Header.Clone()}
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: etag(source.Data), LastModified: time.Now().UTC().Format(time.RFC3339)})
+	case r.Method == http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.Objects[name] = &Object{Data: data, Header: r.Header.Clone()}
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		object, ok := s.Objects[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(object.Data))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(object.Data))
+	case r.Method == http.MethodDelete:
+		delete(s.Objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type listEntry struct {
+	Key          string
+	Size         int
+	ETag         string
+	LastModified string
+}
+
+type commonPrefix struct {
+	Prefix string
+}
+
+func (s *Server) list(w http.ResponseWriter, bucket, prefix, delimiter string) {
+	result := struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		KeyCount       int
+		MaxKeys        int
+		IsTruncated    bool
+		Contents       []listEntry
+		CommonPrefixes []commonPrefix
+	}{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+
+	seen := map[string]bool{}
+	for name, object := range s.Objects {
+		key, ok := strings.CutPrefix(name, bucket+"/")
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if !seen[p] {
+					seen[p] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{p})
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, listEntry{
+			Key:          key,
+			Size:         len(object.Data),
+			ETag:         etag(object.Data),
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+	writeXML(w, result)
+}
+
+// readBody returns the payload, decoding aws-chunked bodies of streaming
+// signatures.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size %q", sizeHex)
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
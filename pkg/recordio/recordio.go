@@ -0,0 +1,177 @@
+package recordio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Writer encodes records with the columns of a model.
+type Writer interface {
+	Write(record map[string]interface{}) error
+	// Flush writes buffered records to the underlying writer.
+	Flush() error
+}
+
+// Options control how values are formatted.
+type Options struct {
+	// DateFormat is the Go layout of datetimes, defaults to RFC 3339.
+	DateFormat string
+	// Location converts datetimes before formatting, nil keeps them.
+	Location *time.Location
+	// Delimiter separates CSV fields, defaults to a comma.
+	Delimiter rune
+	// Header writes the column names as first CSV line.
+	Header bool
+}
+
+func (o Options) formatTime(t time.Time) string {
+	if o.Location != nil {
+		t = t.In(o.Location)
+	}
+	layout := o.DateFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return t.Format(layout)
+}
+
+// New returns the writer of the format.
+func New(format string, w io.Writer, model *models.Model, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSV(w, model, opts), nil
+	case FormatNDJSON:
+		return NewNDJSON(w, model, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid format: %s", format)
+	}
+}
+
+// CSVWriter writes one line per record in the column order of the model.
+// NULL is an empty field.
+type CSVWriter struct {
+	model  *models.Model
+	opts   Options
+	w      *csv.Writer
+	header bool
+}
+
+func NewCSV(w io.Writer, model *models.Model, opts Options) *CSVWriter {
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	return &CSVWriter{model: model, opts: opts, w: cw, header: opts.Header}
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if !c.header {
+		return nil
+	}
+	c.header = false
+	names := make([]string, len(c.model.Columns))
+	for i, column := range c.model.Columns {
+		names[i] = column.Name
+	}
+	return c.w.Write(names)
+}
+
+func (c *CSVWriter) Write(record map[string]interface{}) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(c.model.Columns))
+	for i, column := range c.model.Columns {
+		value, err := c.format(record[column.Name])
+		if err != nil {
+			return fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		row[i] = value
+	}
+	return c.w.Write(row)
+}
+
+func (c *CSVWriter) format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return c.opts.formatTime(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int32, int64, bool, json.Number:
+		return fmt.Sprint(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+func (c *CSVWriter) Flush() error {
+	// an empty export still gets its header
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// NDJSONWriter writes one JSON object per line with the model columns.
+// Columns of type json holding a document are embedded as JSON value.
+type NDJSONWriter struct {
+	model *models.Model
+	opts  Options
+	w     *bufio.Writer
+}
+
+func NewNDJSON(w io.Writer, model *models.Model, opts Options) *NDJSONWriter {
+	return &NDJSONWriter{model: model, opts: opts, w: bufio.NewWriter(w)}
+}
+
+func (n *NDJSONWriter) Write(record map[string]interface{}) error {
+	object := make(map[string]interface{}, len(n.model.Columns))
+	for _, column := range n.model.Columns {
+		value := record[column.Name]
+		switch v := value.(type) {
+		case time.Time:
+			value = n.opts.formatTime(v)
+		case string:
+			if column.Type == models.JSON && json.Valid([]byte(v)) {
+				value = json.RawMessage(v)
+			}
+		}
+		object[column.Name] = value
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	if _, err := n.w.Write(data); err != nil {
+		return err
+	}
+	return n.w.WriteByte('\n')
+}
+
+func (n *NDJSONWriter) Flush() error {
+	return n.w.Flush()
+}
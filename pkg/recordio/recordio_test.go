@@ -0,0 +1,96 @@
+package recordio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var model = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "sensor", Type: models.String},
+		{Name: "value", Type: models.Float},
+		{Name: "count", Type: models.BigInt},
+		{Name: "ok", Type: models.Bool},
+		{Name: "meta", Type: models.JSON},
+	},
+}
+
+var records = []map[string]interface{}{
+	{"time": time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC), "sensor": "hall; 1", "value": 0.1, "count": int64(3), "ok": true, "meta": `{"a":1}`},
+	{"time": time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC), "sensor": nil, "value": nil, "count": 4, "ok": false, "meta": map[string]interface{}{"b": 2}},
+}
+
+func write(t *testing.T, format string, opts Options, records []map[string]interface{}) string {
+	t.Helper()
+	var out strings.Builder
+	w, err := New(format, &out, model, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return out.String()
+}
+
+func TestCSV(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name    string
+		opts    Options
+		records []map[string]interface{}
+		want    string
+	}{
+		{
+			name:    "header",
+			opts:    Options{Header: true},
+			records: records,
+			want: "time,sensor,value,count,ok,meta\n" +
+				"2024-09-01T08:00:00Z,hall; 1,0.1,3,true,\"{\"\"a\"\":1}\"\n" +
+				"2024-09-01T09:00:00Z,,,4,false,\"{\"\"b\"\":2}\"\n",
+		},
+		{
+			name:    "delimiter and date format",
+			opts:    Options{Delimiter: ';', DateFormat: "02.01.2006 15:04", Location: berlin},
+			records: records[:1],
+			want:    "01.09.2024 10:00;\"hall; 1\";0.1;3;true;\"{\"\"a\"\":1}\"\n",
+		},
+		{
+			name: "empty with header",
+			opts: Options{Header: true},
+			want: "time,sensor,value,count,ok,meta\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := write(t, FormatCSV, tt.opts, tt.records); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	want := `{"count":3,"meta":{"a":1},"ok":true,"sensor":"hall; 1","time":"2024-09-01T08:00:00Z","value":0.1}` + "\n" +
+		`{"count":4,"meta":{"b":2},"ok":false,"sensor":null,"time":"2024-09-01T09:00:00Z","value":null}` + "\n"
+	if got := write(t, FormatNDJSON, Options{}, records); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNewInvalidFormat(t *testing.T) {
+	if _, err := New("xml", &strings.Builder{}, model, Options{}); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,247 @@
+package retry
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Policy describes how often and how long to wait before an operation is
+// retried after a transient failure.
+type Policy struct {
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	Multiplier           float64
+	Jitter               float64
+	RetryableStatusCodes []int
+	RetryableSQLStates   []string
+}
+
+type policyConfig struct {
+	MaxAttempts          *int     `yaml:"max_attempts"`
+	InitialBackoff       string   `yaml:"initial_backoff"`
+	MaxBackoff           string   `yaml:"max_backoff"`
+	Multiplier           *float64 `yaml:"multiplier"`
+	Jitter               *float64 `yaml:"jitter"`
+	RetryableStatusCodes []int    `yaml:"retryable_status_codes"`
+	RetryableSQLStates   []string `yaml:"retryable_sqlstates"`
+}
+
+// DefaultPolicy returns the policy used when a plugin has no retry block.
+//
+// SQLSTATEs can be given as full codes (e.g. "40001") or as two character
+// classes (e.g. "08" for all connection exceptions).
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxAttempts:          3,
+		InitialBackoff:       500 * time.Millisecond,
+		MaxBackoff:           30 * time.Second,
+		Multiplier:           2,
+		Jitter:               0.2,
+		RetryableStatusCodes: []int{408, 429, 500, 502, 503, 504},
+		RetryableSQLStates:   []string{"08", "40001", "40P01", "53300", "57P01", "57P02", "57P03"},
+	}
+}
+
+// ParsePolicy reads the optional `retry:` block of a plugin config. Missing
+// keys fall back to the values of DefaultPolicy.
+//
+// Example:
+//
+//	retry:
+//	  max_attempts: 5
+//	  initial_backoff: 1s
+//	  max_backoff: 1m
+//	  multiplier: 2
+//	  jitter: 0.2
+//	  retryable_status_codes: [429, 502, 503]
+//	  retryable_sqlstates: ["08", "40001"]
+func ParsePolicy(pluginConfig map[string]interface{}) (*Policy, error) {
+	policy := DefaultPolicy()
+
+	raw, ok := pluginConfig["retry"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+
+	var cfg policyConfig
+	if err := config.Decode(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid retry config: %v", err)
+	}
+
+	if cfg.MaxAttempts != nil {
+		if *cfg.MaxAttempts < 1 {
+			return nil, errors.New("retry max_attempts must be at least 1")
+		}
+		policy.MaxAttempts = *cfg.MaxAttempts
+	}
+	if cfg.InitialBackoff != "" {
+		d, err := time.ParseDuration(cfg.InitialBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry initial_backoff: %v", err)
+		}
+		policy.InitialBackoff = d
+	}
+	if cfg.MaxBackoff != "" {
+		d, err := time.ParseDuration(cfg.MaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry max_backoff: %v", err)
+		}
+		policy.MaxBackoff = d
+	}
+	if cfg.Multiplier != nil {
+		if *cfg.Multiplier < 1 {
+			return nil, errors.New("retry multiplier must be at least 1")
+		}
+		policy.Multiplier = *cfg.Multiplier
+	}
+	if cfg.Jitter != nil {
+		if *cfg.Jitter < 0 || *cfg.Jitter > 1 {
+			return nil, errors.New("retry jitter must be between 0 and 1")
+		}
+		policy.Jitter = *cfg.Jitter
+	}
+	if cfg.RetryableStatusCodes != nil {
+		policy.RetryableStatusCodes = cfg.RetryableStatusCodes
+	}
+	if cfg.RetryableSQLStates != nil {
+		policy.RetryableSQLStates = cfg.RetryableSQLStates
+	}
+
+	return policy, nil
+}
+
+// StatusError is returned by HTTP based plugins for non successful responses
+// so the policy can decide on the status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+type sqlStateError interface {
+	SQLState() string
+}
+
+// Retryable reports whether err is a transient failure according to the
+// policy.
+func (p *Policy) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range p.RetryableStatusCodes {
+			if code == statusErr.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	var sqlErr sqlStateError
+	if errors.As(err, &sqlErr) {
+		state := sqlErr.SQLState()
+		for _, s := range p.RetryableSQLStates {
+			if strings.HasPrefix(state, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// url.Error and most other failures implement net.Error, only timeouts
+	// and temporary DNS failures are transient, not TLS or lookup errors
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Backoff returns the delay before the given retry (1 based).
+func (p *Policy) Backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Do runs fn until it succeeds, returns a non retryable error or the maximum
+// number of attempts is reached. Every retry is counted under name.
+func (p *Policy) Do(name string, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return err
+		}
+
+		backoff := p.Backoff(attempt)
+		log.WithFields(log.Fields{
+			"name":    name,
+			"attempt": attempt,
+			"backoff": backoff,
+			"error":   err,
+		}).Warn("retrying after transient error")
+		record(name)
+		sleep(backoff)
+	}
+}
+
+var (
+	countsMu sync.Mutex
+	counts   = make(map[string]int)
+)
+
+func record(name string) {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+	counts[name]++
+}
+
+// Counts returns the number of retries per name since the process started.
+func Counts() map[string]int {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+	result := make(map[string]int, len(counts))
+	for name, count := range counts {
+		result[name] = count
+	}
+	return result
+}
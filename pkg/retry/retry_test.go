@@ -0,0 +1,193 @@
+package retry
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    *Policy
+		wantErr bool
+	}{
+		{
+			name:   "defaults without retry block",
+			config: map[string]interface{}{},
+			want:   DefaultPolicy(),
+		},
+		{
+			name: "overrides",
+			config: map[string]interface{}{
+				"retry": map[interface{}]interface{}{
+					"max_attempts":           5,
+					"initial_backoff":        "1s",
+					"max_backoff":            "1m",
+					"jitter":                 0.0,
+					"retryable_status_codes": []interface{}{502},
+				},
+			},
+			want: &Policy{
+				MaxAttempts:          5,
+				InitialBackoff:       time.Second,
+				MaxBackoff:           time.Minute,
+				Multiplier:           2,
+				Jitter:               0,
+				RetryableStatusCodes: []int{502},
+				RetryableSQLStates:   DefaultPolicy().RetryableSQLStates,
+			},
+		},
+		{
+			name: "invalid duration",
+			config: map[string]interface{}{
+				"retry": map[interface{}]interface{}{"initial_backoff": "soon"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid max attempts",
+			config: map[string]interface{}{
+				"retry": map[interface{}]interface{}{"max_attempts": 0},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ParsePolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	policy := DefaultPolicy()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad gateway", fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 502}), true},
+		{"bad request", &StatusError{StatusCode: 400}, false},
+		{"connection failure class", &pq.Error{Code: "08006"}, true},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"plain error", errors.New("boom"), false},
+		{"connection refused", &url.Error{Op: "Post", URL: "http://api", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{"timeout", &url.Error{Op: "Post", URL: "http://api", Err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}}, true},
+		{"temporary dns failure", &url.Error{Op: "Post", URL: "http://api", Err: &net.DNSError{Name: "api", IsTemporary: true}}, true},
+		{"unknown host", &url.Error{Op: "Post", URL: "http://api", Err: &net.DNSError{Name: "api", IsNotFound: true}}, false},
+		{"untrusted certificate", &url.Error{Op: "Post", URL: "https://api", Err: x509.UnknownAuthorityError{}}, false},
+		{"malformed url", &url.Error{Op: "parse", URL: "http://a b", Err: errors.New("invalid character")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryableTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	// the default client does not trust the test certificate
+	_, err := http.Get(server.URL)
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("expected url.Error, got %v", err)
+	}
+	if DefaultPolicy().Retryable(err) {
+		t.Errorf("Retryable(%v) = true, want false", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	policy := DefaultPolicy()
+	policy.MaxAttempts = 3
+
+	calls := 0
+	err := policy.Do("test_success", func() error {
+		calls++
+		if calls < 3 {
+			return &StatusError{StatusCode: 503}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if Counts()["test_success"] != 2 {
+		t.Errorf("expected 2 recorded retries, got %d", Counts()["test_success"])
+	}
+
+	calls = 0
+	err = policy.Do("test_permanent", func() error {
+		calls++
+		return &StatusError{StatusCode: 400}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected a single failing call, got %d calls and error %v", calls, err)
+	}
+
+	calls = 0
+	err = policy.Do("test_exhausted", func() error {
+		calls++
+		return &StatusError{StatusCode: 502}
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("expected 3 failing calls, got %d calls and error %v", calls, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &Policy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+	}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
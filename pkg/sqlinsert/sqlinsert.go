@@ -0,0 +1,196 @@
+// Package sqlinsert upserts records into SQL tables in batches. It is shared
+// by the database/sql destinations, which differ in the placeholder style
+// and the upsert clause of the insert statement.
+package sqlinsert
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	log "github.com/sirupsen/logrus"
+)
+
+// Placeholder returns the bind parameter of the column at index i.
+type Placeholder func(i int) string
+
+// Question is the ? placeholder of SQLite and MySQL.
+func Question(int) string {
+	return "?"
+}
+
+// Dollar is the numbered $1 placeholder of PostgreSQL.
+func Dollar(i int) string {
+	return fmt.Sprintf("$%d", i+1)
+}
+
+// Upsert returns the clause appended to the insert statement, the empty
+// string for plain inserts.
+type Upsert func(model *models.Model) string
+
+// OnConflict updates the other columns of rows with the same unique key,
+// rows of models without unique key are only inserted. PostgreSQL and
+// SQLite support it.
+func OnConflict(model *models.Model) string {
+	if len(model.Unique) == 0 {
+		return ""
+	}
+	var updates []string
+	for _, column := range model.Columns {
+		if !slices.Contains(model.Unique, column.Name) {
+			ident := tmpl.SQLIdent(column.Name)
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", ident, ident))
+		}
+	}
+	if len(updates) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", IdentList(model.Unique))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", IdentList(model.Unique), strings.Join(updates, ", "))
+}
+
+// IdentList returns the quoted, comma separated names.
+func IdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = tmpl.SQLIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Inserter writes the records of a model into a table.
+type Inserter struct {
+	// Name is the destination type used in logs and retry counts.
+	Name  string
+	DB    *sql.DB
+	Model *models.Model
+	// Table is the quoted, possibly schema qualified table name.
+	Table       string
+	BatchSize   int
+	Retry       *retry.Policy
+	Placeholder Placeholder
+	Upsert      Upsert
+}
+
+// Query returns the insert statement.
+func (w *Inserter) Query() string {
+	names := make([]string, len(w.Model.Columns))
+	placeholders := make([]string, len(w.Model.Columns))
+	for i, column := range w.Model.Columns {
+		names[i] = column.Name
+		placeholders[i] = w.Placeholder(i)
+	}
+
+	var stm strings.Builder
+	stm.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		w.Table, IdentList(names), strings.Join(placeholders, ", ")))
+	if w.Upsert != nil {
+		stm.WriteString(w.Upsert(w.Model))
+	}
+	stm.WriteString(";")
+	return stm.String()
+}
+
+// Values returns the arguments of a record, empty strings are NULL and
+// nested JSON values are stored as documents.
+func Values(model *models.Model, record map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(model.Columns))
+	for i, column := range model.Columns {
+		switch value := record[column.Name].(type) {
+		case string:
+			if value == "" {
+				values[i] = nil
+				continue
+			}
+			values[i] = value
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", column.Name, err)
+			}
+			values[i] = string(data)
+		default:
+			values[i] = value
+		}
+	}
+	return values, nil
+}
+
+// Store inserts the records in batches of BatchSize, every batch in its own
+// transaction which is retried as a whole. It returns the number of stored
+// and failed records.
+func (w *Inserter) Store(data []map[string]interface{}) (int, int, error) {
+	q := w.Query()
+	log.WithFields(log.Fields{
+		"query":       q,
+		"destination": w.Name,
+	}).Debug("insert query")
+
+	totalSuccess := 0
+	totalFailed := 0
+	batch := make([][]interface{}, 0, w.BatchSize)
+
+	executeBatch := func() error {
+		tx, err := w.DB.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(q)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		defer stmt.Close()
+
+		for _, values := range batch {
+			if _, err := stmt.Exec(values...); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		return tx.Commit()
+	}
+
+	flush := func() {
+		if err := w.Retry.Do(w.Name, executeBatch); err != nil {
+			log.WithFields(log.Fields{
+				"batch_size": len(batch),
+				"type":       "failed",
+				"error":      err,
+			}).Error("inserting batch")
+			totalFailed += len(batch)
+		} else {
+			log.WithFields(log.Fields{
+				"batch_size": len(batch),
+				"type":       "success",
+			}).Info("inserting batch")
+			totalSuccess += len(batch)
+		}
+		batch = batch[:0]
+	}
+
+	for idx, record := range data {
+		values, err := Values(w.Model, record)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"idx":   idx,
+				"error": err,
+			}).Error("inserting record")
+			totalFailed++
+			continue
+		}
+		batch = append(batch, values)
+		if len(batch) == w.BatchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+
+	return totalSuccess, totalFailed, nil
+}
@@ -0,0 +1,75 @@
+package sqlinsert
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var tickModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "sensor", Type: models.String},
+		{Name: "value", Type: models.Float},
+		{Name: "meta", Type: models.JSON},
+	},
+	Unique: []string{"time", "sensor"},
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  *models.Model
+		upsert Upsert
+		want   string
+	}{
+		{
+			name:   "upsert",
+			model:  tickModel,
+			upsert: OnConflict,
+			want: `INSERT INTO "ticks" ("time", "sensor", "value", "meta") VALUES ($1, $2, $3, $4)` +
+				` ON CONFLICT ("time", "sensor") DO UPDATE SET "value" = EXCLUDED."value", "meta" = EXCLUDED."meta";`,
+		},
+		{
+			name:   "only unique columns",
+			model:  &models.Model{Columns: tickModel.Columns[:2], Unique: tickModel.Unique},
+			upsert: OnConflict,
+			want:   `INSERT INTO "ticks" ("time", "sensor") VALUES ($1, $2) ON CONFLICT ("time", "sensor") DO NOTHING;`,
+		},
+		{
+			name:   "without unique key",
+			model:  &models.Model{Columns: tickModel.Columns[:2]},
+			upsert: OnConflict,
+			want:   `INSERT INTO "ticks" ("time", "sensor") VALUES ($1, $2);`,
+		},
+		{
+			name:  "plain insert",
+			model: tickModel,
+			want:  `INSERT INTO "ticks" ("time", "sensor", "value", "meta") VALUES ($1, $2, $3, $4);`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Inserter{Model: tt.model, Table: `"ticks"`, Placeholder: Dollar, Upsert: tt.upsert}
+			if got := w.Query(); got != tt.want {
+				t.Errorf("Query() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	got, err := Values(tickModel, map[string]interface{}{
+		"sensor": "",
+		"value":  1.5,
+		"meta":   map[string]interface{}{"a": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{nil, nil, 1.5, `{"a":1}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+// Package sqlitedb opens SQLite database files for the sqlite source and
+// destination.
+package sqlitedb
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// DSN returns the data source name of path. Concurrent writers are waited
+// for instead of failing with SQLITE_BUSY, parameters of path (e.g.
+// file:data.db?mode=ro) are kept.
+func DSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_pragma=busy_timeout(5000)"
+}
+
+// Open opens the database file, it is created by the first write if it does
+// not exist.
+func Open(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", DSN(path))
+}
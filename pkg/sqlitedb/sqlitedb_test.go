@@ -0,0 +1,34 @@
+package sqlitedb
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	tests := map[string]string{
+		"data.db":              "data.db?_pragma=busy_timeout(5000)",
+		"file:data.db?mode=ro": "file:data.db?mode=ro&_pragma=busy_timeout(5000)",
+	}
+	for path, want := range tests {
+		if got := DSN(path); got != want {
+			t.Errorf("DSN(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestOpenWithParameters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := Open("file:" + path + "?mode=rwc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy_timeout = %d, %v", timeout, err)
+	}
+}
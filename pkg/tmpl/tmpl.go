@@ -0,0 +1,235 @@
+package tmpl
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Template is a parsed query or path template.
+//
+// Templates use text/template syntax with the window, the `vars:` of the
+// config and `-var` flags as data:
+//
+//	WHERE Mandant = {{ sqlInt .mandant }}
+//	  AND USER_CD >= {{ .start_at | date "02.01.2006 15:04:05" | sqlString }}
+//	  AND USER_CD < {{ .end_at | addDays -1 | date "2006-01-02" | sqlString }}
+type Template struct {
+	tpl *template.Template
+}
+
+// Parse parses the template text. Referencing missing variables is an error
+// when rendering.
+func Parse(name, text string) (*Template, error) {
+	tpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(Funcs()).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", name, err)
+	}
+	return &Template{tpl: tpl}, nil
+}
+
+// Render executes the template with data.
+func (t *Template) Render(data map[string]interface{}) (string, error) {
+	var out strings.Builder
+	if err := t.tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Uses reports whether the template references the variable name as
+// `.name` or `$.name`, e.g. to check that a query has a placeholder.
+func (t *Template) Uses(name string) bool {
+	for _, tpl := range t.tpl.Templates() {
+		if tpl.Tree != nil && uses(tpl.Tree.Root, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func uses(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if uses(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return uses(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if uses(cmd, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if uses(arg, name) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return uses(n.Pipe, name) || uses(n.List, name) || uses(n.ElseList, name)
+	case *parse.RangeNode:
+		return uses(n.Pipe, name) || uses(n.List, name) || uses(n.ElseList, name)
+	case *parse.WithNode:
+		return uses(n.Pipe, name) || uses(n.List, name) || uses(n.ElseList, name)
+	case *parse.TemplateNode:
+		return uses(n.Pipe, name)
+	case *parse.ChainNode:
+		return uses(n.Node, name)
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == name
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	}
+	return false
+}
+
+// Data merges the template variables. Later maps override earlier ones, so
+// callers pass config vars before flag vars before the window.
+func Data(maps ...map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, m := range maps {
+		for key, value := range m {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+// Funcs returns the helper functions available in templates.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"env":         os.Getenv,
+		"default":     defaultValue,
+		"date":        date,
+		"utc":         func(t time.Time) time.Time { return t.UTC() },
+		"in":          in,
+		"addDays":     func(days int, t time.Time) time.Time { return t.AddDate(0, 0, days) },
+		"addMonths":   func(months int, t time.Time) time.Time { return t.AddDate(0, months, 0) },
+		"addDuration": addDuration,
+		"startOfDay":  startOfDay,
+		"sqlString":   SQLString,
+		"sqlIdent":    SQLIdent,
+		"sqlInt":      SQLInt,
+		"sqlList":     SQLList,
+	}
+}
+
+// date formats a time with a Go reference layout. The layouts "german",
+// "date" and "rfc3339" are shortcuts for the common formats.
+func date(layout string, t time.Time) string {
+	switch layout {
+	case "german":
+		layout = "02.01.2006 15:04:05"
+	case "date":
+		layout = "2006-01-02"
+	case "rfc3339":
+		layout = time.RFC3339
+	}
+	return t.Format(layout)
+}
+
+func in(name string, t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+func addDuration(duration string, t time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(d), nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func defaultValue(def interface{}, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+	v := reflect.ValueOf(value)
+	if v.IsZero() {
+		return def
+	}
+	return value
+}
+
+// SQLString quotes a value as SQL string literal, doubling single quotes.
+func SQLString(value interface{}) string {
+	return "'" + strings.ReplaceAll(toString(value), "'", "''") + "'"
+}
+
+// SQLIdent quotes a value as SQL identifier, doubling double quotes.
+func SQLIdent(value interface{}) string {
+	return `"` + strings.ReplaceAll(toString(value), `"`, `""`) + `"`
+}
+
+// SQLInt renders a value as integer literal and fails for anything else, so
+// numeric variables can be used without quoting.
+func SQLInt(value interface{}) (string, error) {
+	s := strings.TrimSpace(toString(value))
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("sqlInt: %q is not an integer", s)
+	}
+	return strconv.FormatInt(i, 10), nil
+}
+
+// SQLList renders a comma separated list as quoted string literals for IN
+// clauses. Slices are accepted as well.
+func SQLList(value interface{}) string {
+	var items []string
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, toString(v.Index(i).Interface()))
+		}
+	} else {
+		for _, item := range strings.Split(toString(value), ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = SQLString(item)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,126 @@
+package tmpl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	t.Setenv("TEST_MANDANT", "2")
+
+	data := Data(
+		map[string]interface{}{"mandant": 1, "name": "O'Brien"},
+		map[string]interface{}{
+			"start_at": time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC),
+			"end_at":   time.Date(2024, 9, 26, 0, 0, 0, 0, time.UTC),
+			"groups":   []string{"a", "b'c"},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "german date",
+			text: `{{ date "german" .start_at }}`,
+			want: "25.09.2024 00:00:00",
+		},
+		{
+			name: "date arithmetic",
+			text: `{{ .end_at | addDays -1 | date "2006-01-02" }}`,
+			want: "2024-09-25",
+		},
+		{
+			name: "duration arithmetic",
+			text: `{{ .start_at | addDuration "-90m" | date "rfc3339" }}`,
+			want: "2024-09-24T22:30:00Z",
+		},
+		{
+			name: "timezone",
+			text: `{{ .start_at | in "Europe/Berlin" | date "15:04" }}`,
+			want: "02:00",
+		},
+		{
+			name: "escaped string literal",
+			text: `WHERE name = {{ sqlString .name }}`,
+			want: "WHERE name = 'O''Brien'",
+		},
+		{
+			name: "integer literal",
+			text: `WHERE Mandant = {{ sqlInt .mandant }}`,
+			want: "WHERE Mandant = 1",
+		},
+		{
+			name:    "integer literal rejects injection",
+			text:    `{{ sqlInt "1 OR 1=1" }}`,
+			wantErr: true,
+		},
+		{
+			name: "list literal",
+			text: `IN ({{ sqlList .groups }})`,
+			want: "IN ('a', 'b''c')",
+		},
+		{
+			name: "identifier",
+			text: `{{ sqlIdent "my\"table" }}`,
+			want: `"my""table"`,
+		},
+		{
+			name: "env with default",
+			text: `{{ env "TEST_MANDANT" | default "1" }}-{{ env "TEST_NOT_SET" | default "1" }}`,
+			want: "2-1",
+		},
+		{
+			name:    "missing variable",
+			text:    `{{ .unknown }}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := Parse(tt.name, tt.text)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := template.Render(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse("broken", "{{ .start_at "); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestUses(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT * FROM t LIMIT {{ .limit }} OFFSET {{ .offset }}":          true,
+		"SELECT * FROM t OFFSET {{$.offset}}":                              true,
+		"SELECT * FROM t OFFSET {{ .offset | sqlInt }}":                    true,
+		"{{ if .paged }}OFFSET {{ .offset }}{{ end }}":                     true,
+		"{{ range .ids }}{{ . }}{{ end }} OFFSET {{ sqlInt $.offset }}":    true,
+		"SELECT t.offset_days FROM t":                                      false,
+		`SELECT ".offset" FROM t WHERE x = {{ .offset_days }}`:             false,
+		"SELECT * FROM t WHERE d >= {{ .start_at | date \"2006-01-02\" }}": false,
+	}
+	for text, want := range tests {
+		tpl, err := Parse("query", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tpl.Uses("offset"); got != want {
+			t.Errorf("Uses(offset) of %q = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package xmlpath
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is a decoded XML element. Names are local names, namespaces are
+// ignored.
+type Node struct {
+	Name     string
+	Attrs    map[string]string
+	Text     string
+	Children []*Node
+}
+
+// UnmarshalXML decodes the element and all its children. Text is the
+// concatenated character data directly inside the element.
+func (n *Node) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	n.Name = start.Name.Local
+	n.Attrs = make(map[string]string, len(start.Attr))
+	for _, attr := range start.Attr {
+		n.Attrs[attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child := &Node{}
+			if err := child.UnmarshalXML(d, t); err != nil {
+				return err
+			}
+			n.Children = append(n.Children, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			n.Text = strings.TrimSpace(text.String())
+			return nil
+		}
+	}
+}
+
+// Path is a parsed XPath-like selector. Supported are element steps with
+// `*` wildcards, 1 based positions and attribute predicates, and a final
+// attribute step:
+//
+//	/catalog/products/product
+//	//product[@type='main']
+//	price/@currency
+//	images/image[2]
+//	.
+//
+// Paths starting with `/` are absolute, `//` matches at any depth. Other
+// paths are relative to a record.
+type Path struct {
+	steps      []step
+	attr       string
+	absolute   bool
+	descendant bool
+}
+
+type step struct {
+	name      string
+	position  int
+	attrName  string
+	attrValue string
+	hasAttr   bool
+}
+
+// Parse parses a selector, the empty selector and `.` select the node
+// itself.
+func Parse(selector string) (Path, error) {
+	s := strings.TrimSpace(selector)
+	var p Path
+	switch {
+	case strings.HasPrefix(s, "//"):
+		p.descendant = true
+		s = s[2:]
+	case strings.HasPrefix(s, "/"):
+		p.absolute = true
+		s = s[1:]
+	}
+	if s == "" || s == "." {
+		if p.absolute || p.descendant {
+			return Path{}, fmt.Errorf("invalid selector %q: missing element", selector)
+		}
+		return p, nil
+	}
+
+	parts := strings.Split(s, "/")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return Path{}, fmt.Errorf("invalid selector %q: empty step, // is only supported at the start", selector)
+		}
+		if strings.HasPrefix(part, "@") {
+			if i != len(parts)-1 {
+				return Path{}, fmt.Errorf("invalid selector %q: attribute must be the last step", selector)
+			}
+			p.attr = localName(part[1:])
+			continue
+		}
+		if part == "." && i == 0 {
+			continue
+		}
+		st, err := parseStep(part)
+		if err != nil {
+			return Path{}, fmt.Errorf("invalid selector %q: %v", selector, err)
+		}
+		p.steps = append(p.steps, st)
+	}
+	if (p.absolute || p.descendant) && (len(p.steps) == 0 || p.attr != "") {
+		return Path{}, fmt.Errorf("invalid selector %q: record selectors must end with an element", selector)
+	}
+	return p, nil
+}
+
+func parseStep(part string) (step, error) {
+	st := step{}
+	name := part
+	if i := strings.IndexByte(part, '['); i >= 0 {
+		if !strings.HasSuffix(part, "]") {
+			return st, fmt.Errorf("missing ] in %q", part)
+		}
+		name = part[:i]
+		predicate := strings.TrimSpace(part[i+1 : len(part)-1])
+		if strings.HasPrefix(predicate, "@") {
+			attr, value, ok := strings.Cut(predicate[1:], "=")
+			if !ok {
+				return st, fmt.Errorf("invalid predicate %q", predicate)
+			}
+			value = strings.TrimSpace(value)
+			if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+				return st, fmt.Errorf("predicate value must be quoted: %q", predicate)
+			}
+			st.attrName = localName(strings.TrimSpace(attr))
+			st.attrValue = value[1 : len(value)-1]
+			st.hasAttr = true
+		} else {
+			position, err := strconv.Atoi(predicate)
+			if err != nil || position < 1 {
+				return st, fmt.Errorf("invalid position %q", predicate)
+			}
+			st.position = position
+		}
+	}
+	if name == "" {
+		return st, fmt.Errorf("missing element name in %q", part)
+	}
+	st.name = localName(name)
+	return st, nil
+}
+
+// localName drops a namespace prefix.
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func (s step) matches(name string, attrs func(string) (string, bool)) bool {
+	if s.name != "*" && s.name != name {
+		return false
+	}
+	if s.hasAttr {
+		value, ok := attrs(s.attrName)
+		return ok && value == s.attrValue
+	}
+	return true
+}
+
+func startAttr(start xml.StartElement) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		for _, attr := range start.Attr {
+			if attr.Name.Local == name {
+				return attr.Value, true
+			}
+		}
+		return "", false
+	}
+}
+
+// IsRecord reports whether the path is absolute or starts with `//` and can
+// select records while streaming.
+func (p Path) IsRecord() bool {
+	return p.absolute || p.descendant
+}
+
+// HasPosition reports whether a step selects by position, e.g. item[2].
+func (p Path) HasPosition() bool {
+	for _, st := range p.steps {
+		if st.position > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the element path of a streaming decoder, from the
+// root to the current element, is selected. Positions are not supported
+// while streaming and are ignored, callers reject them with HasPosition.
+func (p Path) Match(stack []xml.StartElement) bool {
+	if len(p.steps) == 0 || len(stack) < len(p.steps) {
+		return false
+	}
+	if p.absolute && len(stack) != len(p.steps) {
+		return false
+	}
+	offset := len(stack) - len(p.steps)
+	for i, st := range p.steps {
+		element := stack[offset+i]
+		if !st.matches(element.Name.Local, startAttr(element)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the text of the first node or attribute the relative path
+// selects.
+func (p Path) Get(n *Node) (string, bool) {
+	nodes := p.Nodes(n)
+	if len(nodes) == 0 {
+		return "", false
+	}
+	if p.attr != "" {
+		for _, node := range nodes {
+			if value, ok := node.Attrs[p.attr]; ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+	return nodes[0].Text, true
+}
+
+// Nodes returns the elements the relative path selects, in document order.
+func (p Path) Nodes(n *Node) []*Node {
+	current := []*Node{n}
+	for _, st := range p.steps {
+		var next []*Node
+		for _, node := range current {
+			count := 0
+			for _, child := range node.Children {
+				if !st.matches(child.Name, func(name string) (string, bool) {
+					value, ok := child.Attrs[name]
+					return value, ok
+				}) {
+					continue
+				}
+				count++
+				if st.position == 0 || st.position == count {
+					next = append(next, child)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
@@ -0,0 +1,118 @@
+package xmlpath
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const catalog = `<c:catalog xmlns:c="urn:catalog">
+  <c:product id="1" type="main">
+    <name>Schraube</name>
+    <price currency="EUR">0.10</price>
+    <images><image>a.jpg</image><image>b.jpg</image></images>
+    <attr name="color">silber</attr>
+    <attr name="size">M4</attr>
+  </c:product>
+</c:catalog>`
+
+func decode(t *testing.T) *Node {
+	t.Helper()
+	decoder := xml.NewDecoder(strings.NewReader(catalog))
+	root := &Node{}
+	if err := decoder.Decode(root); err != nil {
+		t.Fatal(err)
+	}
+	return root.Children[0]
+}
+
+func TestGet(t *testing.T) {
+	product := decode(t)
+
+	tests := []struct {
+		selector string
+		want     string
+		found    bool
+	}{
+		{"name", "Schraube", true},
+		{"./name", "Schraube", true},
+		{"@id", "1", true},
+		{"price/@currency", "EUR", true},
+		{"images/image", "a.jpg", true},
+		{"images/image[2]", "b.jpg", true},
+		{"images/image[3]", "", false},
+		{"attr[@name='size']", "M4", true},
+		{"*/image[2]", "b.jpg", true},
+		{"missing", "", false},
+		{"price/@missing", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			path, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, found := path.Get(product)
+			if got != tt.want || found != tt.found {
+				t.Errorf("Get() = %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	stack := func(names ...string) []xml.StartElement {
+		var s []xml.StartElement
+		for _, name := range names {
+			local, attr, _ := strings.Cut(name, "@")
+			element := xml.StartElement{Name: xml.Name{Local: local}}
+			if attr != "" {
+				key, value, _ := strings.Cut(attr, "=")
+				element.Attr = []xml.Attr{{Name: xml.Name{Local: key}, Value: value}}
+			}
+			s = append(s, element)
+		}
+		return s
+	}
+
+	tests := []struct {
+		selector string
+		stack    []xml.StartElement
+		want     bool
+	}{
+		{"/catalog/product", stack("catalog", "product"), true},
+		{"/catalog/product", stack("root", "catalog", "product"), false},
+		{"/c:catalog/*", stack("catalog", "product"), true},
+		{"//product", stack("root", "catalog", "product"), true},
+		{"//catalog/product", stack("root", "catalog", "product"), true},
+		{"//product", stack("product", "name"), false},
+		{"//product[@type='main']", stack("catalog", "product@type=main"), true},
+		{"//product[@type='main']", stack("catalog", "product@type=variant"), false},
+	}
+
+	for _, tt := range tests {
+		path, err := Parse(tt.selector)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.selector, err)
+		}
+		if got := path.Match(tt.stack); got != tt.want {
+			t.Errorf("%s Match() = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	for selector, want := range map[string]bool{"//product": false, "//product[2]": true, "/catalog[1]/product[@type='main']": true} {
+		path, _ := Parse(selector)
+		if got := path.HasPosition(); got != want {
+			t.Errorf("%s HasPosition() = %v, want %v", selector, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, selector := range []string{"/", "a//b", "@id/name", "a[0]", "a[x]", "a[@b=c]", "a[@b='c'", "//a/@id"} {
+		if _, err := Parse(selector); err == nil {
+			t.Errorf("Parse(%q) expected error", selector)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatJSONEachRow = "JSONEachRow"
+	FormatRowBinary   = "RowBinary"
+)
+
+type clickhouseConfig struct {
+	URL         string `yaml:"url"`
+	Database    string `yaml:"database"`
+	Table       string `yaml:"table"`
+	Format      string `yaml:"format"`
+	BatchSize   int    `yaml:"batch_size"`
+	Version     string `yaml:"version"`
+	PartitionBy string `yaml:"partition_by"`
+}
+
+// ClickHouseDestination inserts the records through the HTTP interface of
+// ClickHouse. The table is a ReplacingMergeTree ordered by the unique
+// columns, so rows with the same key are deduplicated on merges.
+type ClickHouseDestination struct {
+	Model     *models.Model
+	URL       string
+	Database  string
+	Table     string
+	Format    string
+	BatchSize int
+	// Version is the optional version column of the ReplacingMergeTree, the
+	// row with the highest version is kept.
+	Version string
+	// PartitionBy is an optional partition expression, e.g. toYYYYMM(time).
+	PartitionBy string
+	Auth        *httpauth.Auth
+	Retry       *retry.Policy
+}
+
+func (d *ClickHouseDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := clickhouseConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid clickhouse config: %v", err)
+	}
+	if c.Table == "" {
+		return errors.New("table is required")
+	}
+	d.Table = c.Table
+	d.URL = c.URL
+	if d.URL == "" {
+		d.URL = "http://localhost:8123"
+	}
+	if _, err := url.Parse(d.URL); err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	d.Database = c.Database
+	if d.Database == "" {
+		d.Database = "default"
+	}
+
+	switch strings.ToLower(c.Format) {
+	case "", strings.ToLower(FormatJSONEachRow):
+		d.Format = FormatJSONEachRow
+	case strings.ToLower(FormatRowBinary):
+		d.Format = FormatRowBinary
+	default:
+		return fmt.Errorf("invalid format: %s", c.Format)
+	}
+	if c.BatchSize > 0 {
+		d.BatchSize = c.BatchSize
+	}
+
+	if c.Version != "" {
+		column, ok := findColumn(model, c.Version)
+		if !ok {
+			return fmt.Errorf("version column %s is not a model column", c.Version)
+		}
+		switch column.Type {
+		case models.BigInt, models.Int, models.DateTime:
+		default:
+			return fmt.Errorf("version column %s must be an integer or datetime column", c.Version)
+		}
+		d.Version = c.Version
+	}
+	d.PartitionBy = c.PartitionBy
+
+	authConfig, ok := cfg["auth"]
+	if !ok {
+		authConfig = map[string]interface{}{"type": httpauth.None}
+	}
+	var err error
+	if d.Auth, err = httpauth.Parse(authConfig); err != nil {
+		return err
+	}
+	if d.Retry, err = retry.ParsePolicy(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+func findColumn(model *models.Model, name string) (models.Column, bool) {
+	for _, column := range model.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return models.Column{}, false
+}
+
+func (d *ClickHouseDestination) Close() error {
+	return nil
+}
+
+// ident quotes an identifier with backticks.
+func ident(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (d *ClickHouseDestination) qualified() string {
+	return ident(d.Database) + "." + ident(d.Table)
+}
+
+// nullable reports whether a column may hold NULL. Columns of the ordering
+// key, the version column and datetime columns can not.
+func (d *ClickHouseDestination) nullable(column models.Column) bool {
+	return column.Type != models.DateTime && column.Name != d.Version && !contains(d.Model.Unique, column.Name)
+}
+
+func (d *ClickHouseDestination) getSQLType(column models.Column) string {
+	var t string
+	switch column.Type {
+	case models.BigInt:
+		t = "Int64"
+	case models.Int:
+		t = "Int32"
+	case models.Float:
+		t = "Float64"
+	case models.DateTime, models.DateTimeNullable:
+		t = "DateTime64(6, 'UTC')"
+	case models.Bool:
+		t = "Bool"
+	default:
+		t = "String"
+	}
+	if d.nullable(column) {
+		return "Nullable(" + t + ")"
+	}
+	return t
+}
+
+func (d *ClickHouseDestination) CreateSchema() ([]string, error) {
+	var stm strings.Builder
+
+	stm.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", d.qualified()))
+	for i, column := range d.Model.Columns {
+		stm.WriteString(fmt.Sprintf("    %s %s", ident(column.Name), d.getSQLType(column)))
+		if i < len(d.Model.Columns)-1 {
+			stm.WriteString(",")
+		}
+		stm.WriteString("\n")
+	}
+	stm.WriteString(")\n")
+
+	if d.Version != "" {
+		stm.WriteString(fmt.Sprintf("ENGINE = ReplacingMergeTree(%s)\n", ident(d.Version)))
+	} else {
+		stm.WriteString("ENGINE = ReplacingMergeTree\n")
+	}
+	if d.PartitionBy != "" {
+		stm.WriteString(fmt.Sprintf("PARTITION BY %s\n", d.PartitionBy))
+	}
+	if len(d.Model.Unique) > 0 {
+		keys := make([]string, len(d.Model.Unique))
+		for i, key := range d.Model.Unique {
+			keys[i] = ident(key)
+		}
+		stm.WriteString(fmt.Sprintf("ORDER BY (%s)", strings.Join(keys, ", ")))
+	} else {
+		stm.WriteString("ORDER BY tuple()")
+	}
+
+	return []string{stm.String()}, nil
+}
+
+func (d *ClickHouseDestination) RunSchema() error {
+	queries, err := d.CreateSchema()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range queries {
+		log.WithFields(log.Fields{
+			"query": query,
+		}).Debug("Running schema queries")
+		err := d.Retry.Do("clickhouse", func() error {
+			return d.exec("", strings.NewReader(query))
+		})
+		if err != nil {
+			return fmt.Errorf("error running schema query: %v", err)
+		}
+	}
+	return nil
+}
+
+// InsertQuery returns the insert statement, the rows follow in the body.
+func (d *ClickHouseDestination) InsertQuery() string {
+	names := make([]string, len(d.Model.Columns))
+	for i, column := range d.Model.Columns {
+		names[i] = ident(column.Name)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) FORMAT %s", d.qualified(), strings.Join(names, ", "), d.Format)
+}
+
+// exec posts body to the HTTP interface. With a query the body holds the
+// data, otherwise the body is the query itself.
+func (d *ClickHouseDestination) exec(query string, body io.Reader) error {
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return err
+	}
+	params := u.Query()
+	params.Set("database", d.Database)
+	if query != "" {
+		params.Set("query", query)
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if err := d.Auth.Apply(req); err != nil {
+		return err
+	}
+
+	resp, err := d.Auth.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return &retry.StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+	return nil
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// value converts a record value into the Go type of the column, empty
+// strings of non string columns are NULL.
+func value(record map[string]interface{}, column models.Column) (interface{}, error) {
+	v := record[column.Name]
+	if s, ok := v.(string); ok && s == "" && column.Type != models.String {
+		v = nil
+	}
+	converted, err := convert.Value(v, column.Type, convert.Options{DateLayouts: []string{time.RFC3339Nano}})
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %v", column.Name, err)
+	}
+	return converted, nil
+}
+
+// EncodeJSONEachRow appends the record as one JSON object line. Datetimes
+// are written in UTC as ClickHouse parses them without time zone.
+func (d *ClickHouseDestination) EncodeJSONEachRow(buf *bytes.Buffer, record map[string]interface{}) error {
+	row := make(map[string]interface{}, len(d.Model.Columns))
+	for _, column := range d.Model.Columns {
+		v, err := value(record, column)
+		if err != nil {
+			return err
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format("2006-01-02 15:04:05.000000")
+		}
+		row[column.Name] = v
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// EncodeRowBinary appends the record in the RowBinary format: the columns
+// in table order, little endian numbers, strings prefixed by their length
+// and nullable values by a null marker.
+func (d *ClickHouseDestination) EncodeRowBinary(buf *bytes.Buffer, record map[string]interface{}) error {
+	var row bytes.Buffer
+	for _, column := range d.Model.Columns {
+		v, err := value(record, column)
+		if err != nil {
+			return err
+		}
+		if d.nullable(column) {
+			if v == nil {
+				row.WriteByte(1)
+				continue
+			}
+			row.WriteByte(0)
+		} else if v == nil {
+			return fmt.Errorf("column %s: value is required", column.Name)
+		}
+
+		switch v := v.(type) {
+		case string:
+			row.Write(binary.AppendUvarint(nil, uint64(len(v))))
+			row.WriteString(v)
+		case int64:
+			row.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+		case int:
+			row.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(v))))
+		case float64:
+			row.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+		case bool:
+			if v {
+				row.WriteByte(1)
+			} else {
+				row.WriteByte(0)
+			}
+		case time.Time:
+			row.Write(binary.LittleEndian.AppendUint64(nil, uint64(v.UnixMicro())))
+		default:
+			return fmt.Errorf("column %s: unsupported value type %T", column.Name, v)
+		}
+	}
+	buf.Write(row.Bytes())
+	return nil
+}
+
+func (d *ClickHouseDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	query := d.InsertQuery()
+	log.WithFields(log.Fields{
+		"query":       query,
+		"destination": "clickhouse",
+	}).Debug("insert query")
+
+	encode := d.EncodeJSONEachRow
+	if d.Format == FormatRowBinary {
+		encode = d.EncodeRowBinary
+	}
+
+	totalSuccess := 0
+	totalFailed := 0
+	var buf bytes.Buffer
+	rows := 0
+
+	flush := func() {
+		body := buf.Bytes()
+		err := d.Retry.Do("clickhouse", func() error {
+			return d.exec(query, bytes.NewReader(body))
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"batch_size": rows,
+				"type":       "failed",
+				"error":      err,
+			}).Error("inserting batch")
+			totalFailed += rows
+		} else {
+			log.WithFields(log.Fields{
+				"batch_size": rows,
+				"type":       "success",
+			}).Info("inserting batch")
+			totalSuccess += rows
+		}
+		buf.Reset()
+		rows = 0
+	}
+
+	for idx, record := range data {
+		if err := encode(&buf, record); err != nil {
+			log.WithFields(log.Fields{
+				"idx":   idx,
+				"error": err,
+			}).Error("inserting record")
+			totalFailed++
+			continue
+		}
+		rows++
+		if rows == d.BatchSize {
+			flush()
+		}
+	}
+	if rows > 0 {
+		flush()
+	}
+
+	return totalSuccess, totalFailed, nil
+}
+
+func init() {
+	plugins.RegisterDestination("clickhouse", func() plugins.Destination {
+		return &ClickHouseDestination{
+			BatchSize: 10000,
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var positionModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "position_id", Type: models.BigInt},
+		{Name: "artikel", Type: models.String},
+		{Name: "menge", Type: models.Int},
+		{Name: "preis", Type: models.Float},
+		{Name: "storniert", Type: models.Bool},
+		{Name: "meta", Type: models.JSON},
+	},
+	Unique: []string{"position_id", "time"},
+}
+
+type request struct {
+	query string
+	body  string
+}
+
+// server is a stand-in for the HTTP interface recording the requests. Bodies
+// containing "fail" are rejected.
+func server(t *testing.T) (*httptest.Server, *[]request) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{query: r.URL.Query().Get("query"), body: string(body)})
+		mu.Unlock()
+		if r.URL.Query().Get("database") != "analytics" {
+			http.Error(w, "Code: 81. DB::Exception: Database does not exist", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(string(body), "fail") {
+			http.Error(w, "Code: 27. DB::Exception: Cannot parse input", http.StatusBadRequest)
+			return
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func newDestination(t *testing.T, cfg map[string]interface{}) *ClickHouseDestination {
+	t.Helper()
+	d := &ClickHouseDestination{BatchSize: 10000}
+	cfg["table"] = "positionen"
+	cfg["database"] = "analytics"
+	cfg["retry"] = map[interface{}]interface{}{"max_attempts": 1}
+	if err := d.Init(cfg, positionModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return d
+}
+
+func TestCreateSchema(t *testing.T) {
+	d := newDestination(t, map[string]interface{}{"version": "time", "partition_by": "toYYYYMM(time)"})
+	queries, err := d.CreateSchema()
+	if err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `analytics`.`positionen` (\n" +
+		"    `time` DateTime64(6, 'UTC'),\n" +
+		"    `position_id` Int64,\n" +
+		"    `artikel` Nullable(String),\n" +
+		"    `menge` Nullable(Int32),\n" +
+		"    `preis` Nullable(Float64),\n" +
+		"    `storniert` Nullable(Bool),\n" +
+		"    `meta` Nullable(String)\n" +
+		")\n" +
+		"ENGINE = ReplacingMergeTree(`time`)\n" +
+		"PARTITION BY toYYYYMM(time)\n" +
+		"ORDER BY (`position_id`, `time`)"
+	if len(queries) != 1 || queries[0] != want {
+		t.Errorf("CreateSchema() =\n%s\nwant\n%s", queries, want)
+	}
+
+	// ReplacingMergeTree rejects a Nullable version column
+	d = &ClickHouseDestination{}
+	err = d.Init(map[string]interface{}{"table": "artikel", "version": "revision"}, &models.Model{
+		Columns: []models.Column{
+			{Name: "artikel_nr", Type: models.String},
+			{Name: "revision", Type: models.BigInt},
+		},
+		Unique: []string{"artikel_nr"},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if queries, err = d.CreateSchema(); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	if !strings.Contains(queries[0], "`revision` Int64,") && !strings.Contains(queries[0], "`revision` Int64\n") {
+		t.Errorf("version column is nullable:\n%s", queries[0])
+	}
+	if !strings.Contains(queries[0], "ReplacingMergeTree(`revision`)") {
+		t.Errorf("missing version in engine:\n%s", queries[0])
+	}
+}
+
+func TestRunSchema(t *testing.T) {
+	srv, requests := server(t)
+	d := newDestination(t, map[string]interface{}{"url": srv.URL})
+	if err := d.RunSchema(); err != nil {
+		t.Fatalf("RunSchema() error = %v", err)
+	}
+	if len(*requests) != 1 || !strings.HasPrefix((*requests)[0].body, "CREATE TABLE") {
+		t.Errorf("requests = %v", *requests)
+	}
+}
+
+func TestStoreDataJSONEachRow(t *testing.T) {
+	srv, requests := server(t)
+	d := newDestination(t, map[string]interface{}{"url": srv.URL, "batch_size": 2})
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	ts := time.Date(2024, 9, 1, 10, 0, 0, 0, berlin)
+	data := []map[string]interface{}{
+		{"time": ts, "position_id": int64(1), "artikel": "Schraube", "menge": 3, "preis": 0.1, "storniert": false, "meta": `{"lager":"A"}`},
+		{"time": ts, "position_id": int64(2), "artikel": "", "menge": nil, "preis": nil, "storniert": nil, "meta": nil},
+		{"time": ts, "position_id": "kaputt"},
+		{"time": ts, "position_id": int64(4), "artikel": "fail"},
+	}
+
+	success, failed, err := d.StoreData(data)
+	if err != nil {
+		t.Fatalf("StoreData() error = %v", err)
+	}
+	if success != 2 || failed != 2 {
+		t.Errorf("StoreData() = %d, %d, want 2, 2", success, failed)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(*requests))
+	}
+	first := (*requests)[0]
+	if first.query != "INSERT INTO `analytics`.`positionen` (`time`, `position_id`, `artikel`, `menge`, `preis`, `storniert`, `meta`) FORMAT JSONEachRow" {
+		t.Errorf("query = %s", first.query)
+	}
+	want := `{"artikel":"Schraube","menge":3,"meta":"{\"lager\":\"A\"}","position_id":1,"preis":0.1,"storniert":false,"time":"2024-09-01 08:00:00.000000"}` + "\n" +
+		`{"artikel":"","menge":null,"meta":null,"position_id":2,"preis":null,"storniert":null,"time":"2024-09-01 08:00:00.000000"}` + "\n"
+	if first.body != want {
+		t.Errorf("body =\n%s\nwant\n%s", first.body, want)
+	}
+}
+
+func TestEncodeRowBinary(t *testing.T) {
+	d := newDestination(t, map[string]interface{}{"format": "rowbinary"})
+
+	var buf bytes.Buffer
+	err := d.EncodeRowBinary(&buf, map[string]interface{}{
+		"time":        time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC),
+		"position_id": int64(1),
+		"artikel":     "Ab",
+		"menge":       -1,
+		"preis":       nil,
+		"storniert":   true,
+		"meta":        nil,
+	})
+	if err != nil {
+		t.Fatalf("EncodeRowBinary() error = %v", err)
+	}
+
+	want := "" +
+		"00c005360a210600" + // time: 1725177600000000 µs
+		"0100000000000000" + // position_id
+		"00" + "02" + "4162" + // artikel: not null, length 2, "Ab"
+		"00" + "ffffffff" + // menge: not null, -1
+		"01" + // preis: null
+		"00" + "01" + // storniert: not null, true
+		"01" // meta: null
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("EncodeRowBinary() = %s, want %s", got, want)
+	}
+
+	if err := d.EncodeRowBinary(&buf, map[string]interface{}{"time": time.Now()}); err == nil {
+		t.Error("expected error for missing key column")
+	}
+
+	// a bool in a BigInt column is written as 8 bytes like any Int64
+	buf.Reset()
+	err = d.EncodeRowBinary(&buf, map[string]interface{}{
+		"time":        time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC),
+		"position_id": true,
+		"menge":       false,
+	})
+	if err != nil {
+		t.Fatalf("EncodeRowBinary() error = %v", err)
+	}
+	want = "" +
+		"00c005360a210600" + // time
+		"0100000000000000" + // position_id: true as Int64
+		"01" + // artikel: null
+		"00" + "00000000" + // menge: false as Int32
+		"01" + "01" + "01" // preis, storniert, meta: null
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("EncodeRowBinary() = %s, want %s", got, want)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []map[string]interface{}{
+		{},
+		{"table": "positionen", "format": "csv"},
+		{"table": "positionen", "version": "artikel"},
+		{"table": "positionen", "version": "missing"},
+	}
+	for _, cfg := range tests {
+		d := &ClickHouseDestination{}
+		if err := d.Init(cfg, positionModel); err == nil {
+			t.Errorf("Init(%v) expected error", cfg)
+		}
+	}
+}
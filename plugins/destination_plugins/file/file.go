@@ -0,0 +1,191 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/objstore"
+	"github.com/Talk-Point/databridge/pkg/recordio"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+type fileConfig struct {
+	Path        string `yaml:"path"`
+	Compression string `yaml:"compression"`
+	DateFormat  string `yaml:"date_format"`
+	Timezone    string `yaml:"timezone"`
+	// csv only
+	Delimiter string `yaml:"delimiter"`
+	Header    *bool  `yaml:"header"`
+	// S3 uploads the file to a bucket, path is then the object key.
+	S3 *objstore.Options `yaml:"s3"`
+}
+
+// FileDestination writes the records into a CSV or NDJSON file. The path is
+// a template of the run parameters, the file is written to a temporary file
+// and renamed once complete, or uploaded to S3 with Store.
+type FileDestination struct {
+	Model   *models.Model
+	Format  string
+	Path    *tmpl.Template
+	Options recordio.Options
+	// Compression is auto (gzip for .gz paths), none or gzip.
+	Compression string
+	Store       *objstore.Store
+
+	data map[string]interface{}
+}
+
+func (d *FileDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := fileConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid %s config: %v", d.Format, err)
+	}
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+
+	var err error
+	if d.Path, err = tmpl.Parse("path", c.Path); err != nil {
+		return err
+	}
+	if _, err := fileio.OutputCompression("", c.Compression); err != nil {
+		return err
+	}
+	d.Compression = c.Compression
+
+	if d.Options, err = parseOptions(c); err != nil {
+		return err
+	}
+	if c.S3 != nil {
+		if d.Store, err = objstore.New(*c.S3); err != nil {
+			return err
+		}
+	}
+	d.data = plugins.FetchOpts{}.TemplateData()
+	return nil
+}
+
+func parseOptions(c fileConfig) (recordio.Options, error) {
+	opts := recordio.Options{DateFormat: c.DateFormat, Header: true}
+	if c.Header != nil {
+		opts.Header = *c.Header
+	}
+	if c.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(c.Delimiter)
+		if c.Delimiter == `\t` {
+			delimiter, size = '\t', len(c.Delimiter)
+		}
+		if size != len(c.Delimiter) {
+			return opts, fmt.Errorf("delimiter must be a single character: %q", c.Delimiter)
+		}
+		opts.Delimiter = delimiter
+	}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return opts, err
+		}
+		opts.Location = loc
+	}
+	return opts, nil
+}
+
+// Prepare keeps the run parameters for the path template.
+func (d *FileDestination) Prepare(opts map[string]interface{}) error {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return err
+	}
+	d.data = params.TemplateData()
+	return nil
+}
+
+func (d *FileDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	path, err := d.Path.Render(d.data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error rendering path: %v", err)
+	}
+	compression, err := fileio.OutputCompression(path, d.Compression)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	file, err := fileio.Create(path, compression, d.Store)
+	if err != nil {
+		return 0, 0, err
+	}
+	if d.Store != nil {
+		path = d.Store.URL(path)
+	}
+	writer, err := recordio.New(d.Format, file, d.Model, d.Options)
+	if err != nil {
+		file.Abort()
+		return 0, 0, err
+	}
+
+	totalSuccess, totalFailed, err := write(writer, data)
+	if err != nil {
+		file.Abort()
+		return 0, 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, 0, fmt.Errorf("error writing %s: %v", path, err)
+	}
+
+	log.WithFields(log.Fields{
+		"file":    path,
+		"records": totalSuccess,
+	}).Info("Wrote file")
+	return totalSuccess, totalFailed, nil
+}
+
+// write encodes the records, records which can not be encoded are counted
+// as failed. Write errors of the underlying file are returned.
+func write(writer recordio.Writer, data []map[string]interface{}) (int, int, error) {
+	totalSuccess := 0
+	totalFailed := 0
+	for idx, record := range data {
+		if err := writer.Write(record); err != nil {
+			log.WithFields(log.Fields{
+				"idx":   idx,
+				"error": err,
+			}).Error("writing record")
+			totalFailed++
+			continue
+		}
+		totalSuccess++
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, 0, err
+	}
+	return totalSuccess, totalFailed, nil
+}
+
+// RunSchema is a no-op, files have no schema.
+func (d *FileDestination) RunSchema() error {
+	log.Infof("%s destination has no schema", d.Format)
+	return nil
+}
+
+func (d *FileDestination) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterDestination("csv", func() plugins.Destination {
+		return &FileDestination{Format: recordio.FormatCSV}
+	})
+	plugins.RegisterDestination("ndjson", func() plugins.Destination {
+		return &FileDestination{Format: recordio.FormatNDJSON}
+	})
+}
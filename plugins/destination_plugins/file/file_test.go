@@ -0,0 +1,229 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/objstore/s3test"
+)
+
+var tickModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "sensor", Type: models.String},
+		{Name: "value", Type: models.Float},
+	},
+}
+
+var ticks = []map[string]interface{}{
+	{"time": time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC), "sensor": "a", "value": 1.5},
+	{"time": time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC), "sensor": "b", "value": func() {}},
+	{"time": time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC), "sensor": "c", "value": nil},
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStoreData(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		format string
+		cfg    map[string]interface{}
+		file   string
+		want   string
+	}{
+		{
+			name:   "csv with path template",
+			format: "csv",
+			cfg: map[string]interface{}{
+				"path": filepath.Join(dir, `{{ .name }}/{{ .start_at | date "2006-01-02" }}_{{ .mandant }}.csv`),
+			},
+			file: filepath.Join(dir, "ticks/2024-09-01_1.csv"),
+			want: "time,sensor,value\n2024-09-01T08:00:00Z,a,1.5\n2024-09-01T10:00:00Z,c,\n",
+		},
+		{
+			name:   "csv options",
+			format: "csv",
+			cfg: map[string]interface{}{
+				"path":        filepath.Join(dir, "options.csv"),
+				"delimiter":   ";",
+				"header":      false,
+				"date_format": "02.01.2006 15:04",
+				"timezone":    "Europe/Berlin",
+			},
+			file: filepath.Join(dir, "options.csv"),
+			want: "01.09.2024 10:00;a;1.5\n01.09.2024 12:00;c;\n",
+		},
+		{
+			name:   "ndjson gzip",
+			format: "ndjson",
+			cfg:    map[string]interface{}{"path": filepath.Join(dir, "{{ .name }}.ndjson.gz")},
+			file:   filepath.Join(dir, "ticks.ndjson.gz"),
+			want: `{"sensor":"a","time":"2024-09-01T08:00:00Z","value":1.5}` + "\n" +
+				`{"sensor":"c","time":"2024-09-01T10:00:00Z","value":null}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &FileDestination{Format: tt.format}
+			if err := d.Init(tt.cfg, tickModel); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			err := d.Prepare(map[string]interface{}{
+				"name":     "ticks",
+				"start_at": time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+				"end_at":   time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+				"vars":     map[string]interface{}{"mandant": 1},
+			})
+			if err != nil {
+				t.Fatalf("Prepare() error = %v", err)
+			}
+
+			success, failed, err := d.StoreData(ticks)
+			if err != nil || success != 2 || failed != 1 {
+				t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+			}
+			if got := read(t, tt.file); got != tt.want {
+				t.Errorf("file =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			// only the target is left, the temporary file was renamed
+			entries, _ := os.ReadDir(filepath.Dir(tt.file))
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("temporary file %s left", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestStoreDataMissingVariable(t *testing.T) {
+	dir := t.TempDir()
+	d := &FileDestination{Format: "csv"}
+	if err := d.Init(map[string]interface{}{"path": filepath.Join(dir, "{{ .missing }}.csv")}, tickModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if _, _, err := d.StoreData(ticks); err == nil {
+		t.Error("expected error for missing variable")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files, got %d", len(entries))
+	}
+}
+
+func TestStdout(t *testing.T) {
+	var out strings.Builder
+	d := &StdoutDestination{Out: &out}
+	if err := d.Init(map[string]interface{}{"format": "csv", "delimiter": `\t`}, tickModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	success, failed, err := d.StoreData(ticks)
+	if err != nil || success != 2 || failed != 1 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+	want := "time\tsensor\tvalue\n2024-09-01T08:00:00Z\ta\t1.5\n2024-09-01T10:00:00Z\tc\t\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []map[string]interface{}{
+		{},
+		{"path": "out.csv", "compression": "zstd"},
+		{"path": "out.csv", "delimiter": ";;"},
+		{"path": "out.csv", "timezone": "Mars/Olympus"},
+		{"path": "{{ .name"},
+	}
+	for _, cfg := range tests {
+		d := &FileDestination{Format: "csv"}
+		if err := d.Init(cfg, tickModel); err == nil {
+			t.Errorf("Init(%v) expected error", cfg)
+		}
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{"format": "xml"},
+		{"s3": map[string]interface{}{"bucket": "archive"}},
+	} {
+		d := &StdoutDestination{}
+		if err := d.Init(cfg, tickModel); err == nil {
+			t.Errorf("stdout Init(%v) expected error", cfg)
+		}
+	}
+}
+
+func TestStoreDataS3(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	d := &FileDestination{Format: "ndjson"}
+	err := d.Init(map[string]interface{}{
+		"path": "exports/{{ .name }}.ndjson.gz",
+		"s3": map[string]interface{}{
+			"endpoint":   server.URL,
+			"region":     "eu-central-1",
+			"bucket":     "archive",
+			"access_key": map[string]interface{}{"value": "minio"},
+			"secret_key": map[string]interface{}{"value": "minio123"},
+			"path_style": true,
+			"sse":        map[string]interface{}{"type": "s3"},
+		},
+	}, tickModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := d.Prepare(map[string]interface{}{"name": "ticks"}); err != nil {
+		t.Fatal(err)
+	}
+	success, failed, err := d.StoreData(ticks)
+	if err != nil || success != 2 || failed != 1 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+
+	object := server.Get("archive", "exports/ticks.ndjson.gz")
+	if object == nil {
+		t.Fatalf("object not uploaded, keys %v", server.Keys("archive"))
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(object.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("uploaded %d lines, want 2", lines)
+	}
+	if got := object.Header.Get("X-Amz-Server-Side-Encryption"); got != "AES256" {
+		t.Errorf("sse header = %q", got)
+	}
+}
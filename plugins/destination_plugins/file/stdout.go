@@ -0,0 +1,81 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/recordio"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+type stdoutConfig struct {
+	fileConfig `yaml:",inline"`
+	Format     string `yaml:"format"`
+}
+
+// StdoutDestination prints the records as NDJSON (default) or CSV, e.g. to
+// check the result of a query. Logs go to stderr and stay separate.
+type StdoutDestination struct {
+	Model   *models.Model
+	Format  string
+	Options recordio.Options
+	Out     io.Writer
+}
+
+func (d *StdoutDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := stdoutConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid stdout config: %v", err)
+	}
+	if c.S3 != nil {
+		return errors.New("s3 is not supported by the stdout destination")
+	}
+	switch c.Format {
+	case "":
+		d.Format = recordio.FormatNDJSON
+	case recordio.FormatCSV, recordio.FormatNDJSON:
+		d.Format = c.Format
+	default:
+		return fmt.Errorf("invalid format: %s", c.Format)
+	}
+
+	var err error
+	if d.Options, err = parseOptions(c.fileConfig); err != nil {
+		return err
+	}
+	if d.Out == nil {
+		d.Out = os.Stdout
+	}
+	return nil
+}
+
+func (d *StdoutDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	writer, err := recordio.New(d.Format, d.Out, d.Model, d.Options)
+	if err != nil {
+		return 0, 0, err
+	}
+	return write(writer, data)
+}
+
+// RunSchema is a no-op.
+func (d *StdoutDestination) RunSchema() error {
+	log.Info("stdout destination has no schema")
+	return nil
+}
+
+func (d *StdoutDestination) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterDestination("stdout", func() plugins.Destination {
+		return &StdoutDestination{}
+	})
+}
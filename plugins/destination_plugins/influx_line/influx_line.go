@@ -0,0 +1,437 @@
+package influx_line
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TransportHTTP = "http"
+	TransportTCP  = "tcp"
+)
+
+type influxConfig struct {
+	Transport   string          `yaml:"transport"`
+	URL         string          `yaml:"url"`
+	Path        string          `yaml:"path"`
+	Org         string          `yaml:"org"`
+	Bucket      string          `yaml:"bucket"`
+	Token       httpauth.Secret `yaml:"token"`
+	Address     string          `yaml:"address"`
+	Measurement string          `yaml:"measurement"`
+	Precision   string          `yaml:"precision"`
+	BatchSize   int             `yaml:"batch_size"`
+}
+
+// InfluxLineDestination writes the records in the InfluxDB line protocol,
+// over HTTP to InfluxDB v2 or QuestDB, or over TCP to the QuestDB ILP port.
+// The column roles of the model decide which columns are the measurement,
+// tags, fields and the timestamp.
+type InfluxLineDestination struct {
+	Model     *models.Model
+	Transport string
+	// WriteURL is the complete write endpoint including org, bucket and
+	// precision for the HTTP transport.
+	WriteURL string
+	Token    string
+	Address  string
+	// Measurement is the measurement name, unless a column has the
+	// measurement role.
+	Measurement string
+	Precision   time.Duration
+	BatchSize   int
+	Retry       *retry.Policy
+
+	measurementColumn string
+	timestampColumn   string
+	tags              []string
+	fields            []models.Column
+	client            *http.Client
+	conn              net.Conn
+}
+
+func (d *InfluxLineDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := influxConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid influx_line config: %v", err)
+	}
+
+	if err := d.roles(model); err != nil {
+		return err
+	}
+	d.Measurement = c.Measurement
+	if d.measurementColumn == "" && d.Measurement == "" {
+		return errors.New("measurement is required without a measurement column")
+	}
+
+	switch c.Precision {
+	case "", "ns":
+		c.Precision = "ns"
+		d.Precision = time.Nanosecond
+	case "us":
+		d.Precision = time.Microsecond
+	case "ms":
+		d.Precision = time.Millisecond
+	case "s":
+		d.Precision = time.Second
+	default:
+		return fmt.Errorf("invalid precision: %s", c.Precision)
+	}
+
+	if c.BatchSize > 0 {
+		d.BatchSize = c.BatchSize
+	}
+
+	var err error
+	d.Transport = c.Transport
+	switch d.Transport {
+	case "", TransportHTTP:
+		d.Transport = TransportHTTP
+		if c.URL == "" {
+			return errors.New("url is required for the http transport")
+		}
+		if d.WriteURL, err = writeURL(c); err != nil {
+			return err
+		}
+		if d.Token, err = c.Token.Resolve(); err != nil {
+			return err
+		}
+		d.client = &http.Client{Timeout: time.Minute}
+	case TransportTCP:
+		if c.Address == "" {
+			return errors.New("address is required for the tcp transport")
+		}
+		if c.Precision != "ns" {
+			return errors.New("the tcp transport only supports precision ns")
+		}
+		d.Address = c.Address
+	default:
+		return fmt.Errorf("invalid transport: %s", c.Transport)
+	}
+
+	if d.Retry, err = retry.ParsePolicy(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// roles assigns the columns by their role. Without roles the first
+// datetime column is the timestamp and every other column a field.
+func (d *InfluxLineDestination) roles(model *models.Model) error {
+	for _, column := range model.Columns {
+		switch column.Role {
+		case models.RoleMeasurement:
+			if d.measurementColumn != "" {
+				return errors.New("only one column can be the measurement")
+			}
+			d.measurementColumn = column.Name
+		case models.RoleTag:
+			d.tags = append(d.tags, column.Name)
+		case models.RoleTimestamp:
+			if d.timestampColumn != "" {
+				return errors.New("only one column can be the timestamp")
+			}
+			if column.Type != models.DateTime && column.Type != models.DateTimeNullable {
+				return fmt.Errorf("timestamp column %s must be a datetime column", column.Name)
+			}
+			d.timestampColumn = column.Name
+		}
+	}
+	if d.timestampColumn == "" {
+		for _, column := range model.Columns {
+			if column.Role == "" && (column.Type == models.DateTime || column.Type == models.DateTimeNullable) {
+				d.timestampColumn = column.Name
+				break
+			}
+		}
+	}
+
+	explicit := false
+	for _, column := range model.Columns {
+		if column.Role == models.RoleField {
+			explicit = true
+			d.fields = append(d.fields, column)
+		}
+	}
+	if !explicit {
+		for _, column := range model.Columns {
+			if column.Role == "" && column.Name != d.timestampColumn {
+				d.fields = append(d.fields, column)
+			}
+		}
+	}
+	if len(d.fields) == 0 {
+		return errors.New("at least one field column is required")
+	}
+	sort.Strings(d.tags)
+	return nil
+}
+
+func writeURL(c influxConfig) (string, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %v", err)
+	}
+	path := c.Path
+	if path == "" {
+		path = "/api/v2/write"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+
+	params := u.Query()
+	if c.Org != "" {
+		params.Set("org", c.Org)
+	}
+	if c.Bucket != "" {
+		params.Set("bucket", c.Bucket)
+	}
+	params.Set("precision", c.Precision)
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+func (d *InfluxLineDestination) Close() error {
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
+
+// RunSchema is a no-op, measurements are created by the first write.
+func (d *InfluxLineDestination) RunSchema() error {
+	log.Info("influx_line destination has no schema, measurements are created on write")
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Line returns the record in line protocol, nil and empty tags and nil
+// fields are left out. A record without fields can not be written.
+func (d *InfluxLineDestination) Line(record map[string]interface{}) (string, error) {
+	var line strings.Builder
+
+	measurement := d.Measurement
+	if d.measurementColumn != "" {
+		if m := fmt.Sprint(record[d.measurementColumn]); record[d.measurementColumn] != nil && m != "" {
+			measurement = m
+		}
+	}
+	if measurement == "" {
+		return "", errors.New("measurement is empty")
+	}
+	line.WriteString(measurementEscaper.Replace(measurement))
+
+	for _, tag := range d.tags {
+		value := record[tag]
+		if value == nil {
+			continue
+		}
+		str := fmt.Sprint(value)
+		if t, ok := value.(time.Time); ok {
+			str = t.Format(time.RFC3339)
+		}
+		if str == "" {
+			continue
+		}
+		line.WriteString("," + keyEscaper.Replace(tag) + "=" + keyEscaper.Replace(str))
+	}
+
+	written := 0
+	for _, column := range d.fields {
+		value, err := fieldValue(record[column.Name], column.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		if value == "" {
+			continue
+		}
+		if written == 0 {
+			line.WriteString(" ")
+		} else {
+			line.WriteString(",")
+		}
+		line.WriteString(keyEscaper.Replace(column.Name) + "=" + value)
+		written++
+	}
+	if written == 0 {
+		return "", errors.New("record has no field values")
+	}
+
+	if d.timestampColumn != "" {
+		switch t := record[d.timestampColumn].(type) {
+		case time.Time:
+			line.WriteString(" " + strconv.FormatInt(t.UnixNano()/int64(d.Precision), 10))
+		case nil:
+		default:
+			return "", fmt.Errorf("column %s: timestamp is %T", d.timestampColumn, t)
+		}
+	}
+	return line.String(), nil
+}
+
+// fieldValue formats a field value, the empty string means no value.
+func fieldValue(value interface{}, columnType models.ColumnType) (string, error) {
+	if s, ok := value.(string); ok && s == "" && columnType != models.String {
+		return "", nil
+	}
+	v, err := convert.Value(value, columnType, convert.Options{DateLayouts: []string{time.RFC3339Nano}})
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case int:
+		return strconv.Itoa(v) + "i", nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return `"` + v.Format(time.RFC3339Nano) + `"`, nil
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`, nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// write sends a batch of lines.
+func (d *InfluxLineDestination) write(body []byte) error {
+	if d.Transport == TransportTCP {
+		return d.writeTCP(body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.WriteURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if d.Token != "" {
+		req.Header.Set("Authorization", "Token "+d.Token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &retry.StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+	return nil
+}
+
+// writeTCP writes to the kept connection, a broken connection is closed and
+// opened again on the retry. The server does not acknowledge writes, so
+// only writes that failed before any byte was sent are retried, resending a
+// partially sent batch would duplicate its first lines.
+func (d *InfluxLineDestination) writeTCP(body []byte) error {
+	if d.conn == nil {
+		conn, err := net.DialTimeout("tcp", d.Address, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		d.conn = conn
+	}
+	d.conn.SetWriteDeadline(time.Now().Add(time.Minute))
+	n, err := d.conn.Write(body)
+	if err != nil {
+		d.conn.Close()
+		d.conn = nil
+		if n > 0 {
+			// not wrapped, the retry policy must not see the network error
+			return fmt.Errorf("connection broke after %d of %d bytes, not retried: %v", n, len(body), err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *InfluxLineDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	totalSuccess := 0
+	totalFailed := 0
+	var buf bytes.Buffer
+	lines := 0
+
+	flush := func() {
+		body := buf.Bytes()
+		if err := d.Retry.Do("influx_line", func() error { return d.write(body) }); err != nil {
+			log.WithFields(log.Fields{
+				"batch_size": lines,
+				"type":       "failed",
+				"error":      err,
+			}).Error("writing batch")
+			totalFailed += lines
+		} else {
+			log.WithFields(log.Fields{
+				"batch_size": lines,
+				"type":       "success",
+			}).Info("writing batch")
+			totalSuccess += lines
+		}
+		buf.Reset()
+		lines = 0
+	}
+
+	for idx, record := range data {
+		line, err := d.Line(record)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"idx":   idx,
+				"error": err,
+			}).Error("writing record")
+			totalFailed++
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		lines++
+		if lines == d.BatchSize {
+			flush()
+		}
+	}
+	if lines > 0 {
+		flush()
+	}
+
+	return totalSuccess, totalFailed, nil
+}
+
+func init() {
+	plugins.RegisterDestination("influx_line", func() plugins.Destination {
+		return &InfluxLineDestination{
+			BatchSize: 5000,
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package influx_line
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/retry"
+)
+
+var sensorModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "mandant", Type: models.Int, Role: models.RoleTag},
+		{Name: "time", Type: models.DateTime, Role: models.RoleTimestamp},
+		{Name: "sensor", Type: models.String, Role: models.RoleTag},
+		{Name: "value", Type: models.Float, Role: models.RoleField},
+		{Name: "count", Type: models.BigInt, Role: models.RoleField},
+		{Name: "ok", Type: models.Bool, Role: models.RoleField},
+		{Name: "note", Type: models.String, Role: models.RoleField},
+	},
+	Unique: []string{"mandant", "time", "sensor"},
+}
+
+var ts = time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+
+func TestLine(t *testing.T) {
+	d := &InfluxLineDestination{}
+	err := d.Init(map[string]interface{}{"url": "http://localhost:8086", "measurement": "ticks"}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		record  map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "all values",
+			record: map[string]interface{}{"mandant": 1, "time": ts, "sensor": "hall 1", "value": 21.5, "count": int64(3), "ok": true, "note": `say "hi"`},
+			want:   `ticks,mandant=1,sensor=hall\ 1 value=21.5,count=3i,ok=true,note="say \"hi\"" 1725177600000000000`,
+		},
+		{
+			name:   "missing values are left out",
+			record: map[string]interface{}{"mandant": 1, "time": ts, "sensor": "", "value": nil, "count": int64(0), "ok": nil, "note": nil},
+			want:   `ticks,mandant=1 count=0i 1725177600000000000`,
+		},
+		{
+			name:    "no fields",
+			record:  map[string]interface{}{"mandant": 1, "time": ts, "sensor": "a"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid field",
+			record:  map[string]interface{}{"time": ts, "value": "warm"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Line(tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Line() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Line() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineDefaultRoles(t *testing.T) {
+	model := &models.Model{
+		Columns: []models.Column{
+			{Name: "kind", Type: models.String, Role: models.RoleMeasurement},
+			{Name: "time", Type: models.DateTime},
+			{Name: "value", Type: models.Float},
+		},
+	}
+	d := &InfluxLineDestination{}
+	if err := d.Init(map[string]interface{}{"url": "http://localhost:9000", "precision": "s"}, model); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	got, err := d.Line(map[string]interface{}{"kind": "temp,c", "time": ts, "value": 1.0})
+	if err != nil {
+		t.Fatalf("Line() error = %v", err)
+	}
+	if want := `temp\,c value=1 1725177600`; got != want {
+		t.Errorf("Line() = %s, want %s", got, want)
+	}
+	if d.WriteURL != "http://localhost:9000/api/v2/write?precision=s" {
+		t.Errorf("WriteURL = %s", d.WriteURL)
+	}
+}
+
+func TestStoreDataHTTP(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("bucket") != "sensors" || r.URL.Query().Get("org") != "tp" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	t.Setenv("INFLUX_TOKEN", "secret")
+	d := &InfluxLineDestination{BatchSize: 5000}
+	err := d.Init(map[string]interface{}{
+		"url":         srv.URL,
+		"org":         "tp",
+		"bucket":      "sensors",
+		"token":       map[interface{}]interface{}{"env": "INFLUX_TOKEN"},
+		"measurement": "ticks",
+		"batch_size":  2,
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	data := []map[string]interface{}{
+		{"mandant": 1, "time": ts, "sensor": "a", "value": 1.0},
+		{"mandant": 1, "time": ts.Add(time.Second), "sensor": "a", "value": 2.0},
+		{"mandant": 1, "time": ts, "sensor": "b"},
+		{"mandant": 1, "time": ts, "sensor": "b", "value": 3.0},
+	}
+	success, failed, err := d.StoreData(data)
+	if err != nil || success != 3 || failed != 1 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+	want := []string{
+		"ticks,mandant=1,sensor=a value=1 1725177600000000000\nticks,mandant=1,sensor=a value=2 1725177601000000000\n",
+		"ticks,mandant=1,sensor=b value=3 1725177600000000000\n",
+	}
+	if len(bodies) != 2 || bodies[0] != want[0] || bodies[1] != want[1] {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
+
+func TestStoreDataTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	d := &InfluxLineDestination{BatchSize: 5000}
+	err = d.Init(map[string]interface{}{
+		"transport":   "tcp",
+		"address":     listener.Addr().String(),
+		"measurement": "ticks",
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	success, failed, err := d.StoreData([]map[string]interface{}{
+		{"mandant": 2, "time": ts, "sensor": "a", "value": 1.5},
+	})
+	if err != nil || success != 1 || failed != 0 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+	d.Close()
+
+	select {
+	case line := <-lines:
+		if want := "ticks,mandant=2,sensor=a value=1.5 1725177600000000000"; line != want {
+			t.Errorf("line = %s, want %s", line, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no line received")
+	}
+}
+
+// brokenConn fails every write after accepting n bytes.
+type brokenConn struct {
+	net.Conn
+	n int
+}
+
+func (c *brokenConn) Write(p []byte) (int, error) {
+	return min(c.n, len(p)), &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}
+}
+
+func (c *brokenConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *brokenConn) Close() error { return nil }
+
+func TestWriteTCPPartial(t *testing.T) {
+	policy := retry.DefaultPolicy()
+	d := &InfluxLineDestination{Transport: TransportTCP}
+
+	// nothing was sent, the batch can be sent again
+	d.conn = &brokenConn{n: 0}
+	if err := d.write([]byte("ticks value=1\n")); !policy.Retryable(err) {
+		t.Errorf("write() error = %v, want retryable", err)
+	}
+	if d.conn != nil {
+		t.Error("broken connection was kept")
+	}
+
+	// a resend would duplicate the sent part
+	d.conn = &brokenConn{n: 5}
+	if err := d.write([]byte("ticks value=1\n")); err == nil || policy.Retryable(err) {
+		t.Errorf("write() error = %v, want not retryable", err)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"measurement": "ticks"},
+		{"url": "http://localhost:8086"},
+		{"url": "http://localhost:8086", "measurement": "ticks", "precision": "m"},
+		{"transport": "tcp", "measurement": "ticks"},
+		{"transport": "tcp", "address": "localhost:9009", "measurement": "ticks", "precision": "ms"},
+		{"transport": "udp", "measurement": "ticks"},
+	}
+	for _, cfg := range tests {
+		d := &InfluxLineDestination{}
+		if err := d.Init(cfg, sensorModel); err == nil {
+			t.Errorf("Init(%v) expected error", cfg)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/objstore"
+	"github.com/Talk-Point/databridge/plugins"
+	goparquet "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	log "github.com/sirupsen/logrus"
+)
+
+// PartitionNone turns off the default partitioning.
+const PartitionNone = "none"
+
+type parquetConfig struct {
+	Path         string `yaml:"path"`
+	PartitionBy  string `yaml:"partition_by"`
+	Timezone     string `yaml:"timezone"`
+	Compression  string `yaml:"compression"`
+	RowGroupSize int64  `yaml:"row_group_size"`
+	FilePrefix   string `yaml:"file_prefix"`
+	// S3 uploads the files to a bucket, path is then the key prefix.
+	S3 *objstore.Options `yaml:"s3"`
+}
+
+// ParquetDestination writes the records into Parquet files below Path, or
+// below the Path prefix of a bucket with Store. The files are split by the
+// day of the PartitionBy column into `day=YYYY-MM-DD` directories, which
+// defaults to the first datetime column of the model.
+type ParquetDestination struct {
+	Model        *models.Model
+	Path         string
+	PartitionBy  string
+	Location     *time.Location
+	Compression  compress.Codec
+	RowGroupSize int64
+	FilePrefix   string
+	Schema       *goparquet.Schema
+	Store        *objstore.Store
+}
+
+func (d *ParquetDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := parquetConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid parquet config: %v", err)
+	}
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+	d.Path = c.Path
+
+	switch c.PartitionBy {
+	case "":
+		// partitioned by day of the first datetime column by default
+		for _, column := range model.Columns {
+			if column.Type == models.DateTime {
+				d.PartitionBy = column.Name
+				break
+			}
+		}
+	case PartitionNone:
+	default:
+		column, ok := findColumn(model, c.PartitionBy)
+		if !ok {
+			return fmt.Errorf("partition_by column %s is not a model column", c.PartitionBy)
+		}
+		if column.Type != models.DateTime && column.Type != models.DateTimeNullable {
+			return fmt.Errorf("partition_by column %s must be a datetime column", c.PartitionBy)
+		}
+		d.PartitionBy = c.PartitionBy
+	}
+
+	var err error
+	if d.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+	if d.Compression, err = codec(c.Compression); err != nil {
+		return err
+	}
+
+	d.RowGroupSize = c.RowGroupSize
+	if d.RowGroupSize <= 0 {
+		d.RowGroupSize = 100000
+	}
+	d.FilePrefix = c.FilePrefix
+	if d.FilePrefix == "" {
+		d.FilePrefix = "part"
+	}
+	if c.S3 != nil {
+		if d.Store, err = objstore.New(*c.S3); err != nil {
+			return err
+		}
+	}
+
+	d.Schema = Schema(model)
+	return nil
+}
+
+func findColumn(model *models.Model, name string) (models.Column, bool) {
+	for _, column := range model.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return models.Column{}, false
+}
+
+func codec(name string) (compress.Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "snappy":
+		return &goparquet.Snappy, nil
+	case "none", "uncompressed":
+		return &goparquet.Uncompressed, nil
+	case "gzip":
+		return &goparquet.Gzip, nil
+	case "zstd":
+		return &goparquet.Zstd, nil
+	case "lz4":
+		return &goparquet.Lz4Raw, nil
+	case "brotli":
+		return &goparquet.Brotli, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", name)
+	}
+}
+
+// Schema returns the Parquet schema of the model. Datetime columns are
+// stored as UTC timestamps in microseconds and are the only required
+// columns.
+func Schema(model *models.Model) *goparquet.Schema {
+	group := goparquet.Group{}
+	for _, column := range model.Columns {
+		group[column.Name] = node(column.Type)
+	}
+	return goparquet.NewSchema("record", group)
+}
+
+func node(columnType models.ColumnType) goparquet.Node {
+	switch columnType {
+	case models.BigInt:
+		return goparquet.Optional(goparquet.Int(64))
+	case models.Int:
+		return goparquet.Optional(goparquet.Int(32))
+	case models.Float:
+		return goparquet.Optional(goparquet.Leaf(goparquet.DoubleType))
+	case models.DateTime:
+		return goparquet.Timestamp(goparquet.Microsecond)
+	case models.DateTimeNullable:
+		return goparquet.Optional(goparquet.Timestamp(goparquet.Microsecond))
+	case models.Bool:
+		return goparquet.Optional(goparquet.Leaf(goparquet.BooleanType))
+	case models.JSON:
+		return goparquet.Optional(goparquet.JSON())
+	default:
+		return goparquet.Optional(goparquet.String())
+	}
+}
+
+// StoreData writes one file per partition. Records whose values do not fit
+// the column types are counted as errored.
+func (d *ParquetDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	partitions := make(map[string][]map[string]interface{})
+	errored := 0
+	for _, record := range data {
+		row, err := d.row(record)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"record": record,
+				"error":  err,
+			}).Error("Error converting record")
+			errored++
+			continue
+		}
+		partition := d.partition(row)
+		partitions[partition] = append(partitions[partition], row)
+	}
+
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	run := time.Now().UTC().Format("20060102T150405")
+	success := 0
+	for _, key := range keys {
+		rows := partitions[key]
+		path, err := d.write(filepath.Join(d.Path, key), run, rows)
+		if err != nil {
+			return success, len(data) - success, err
+		}
+		log.WithFields(log.Fields{
+			"file": path,
+			"rows": len(rows),
+		}).Info("Wrote parquet file")
+		success += len(rows)
+	}
+
+	return success, errored, nil
+}
+
+// partition returns the directory of a row relative to Path.
+func (d *ParquetDestination) partition(row map[string]interface{}) string {
+	if d.PartitionBy == "" {
+		return ""
+	}
+	t, ok := row[d.PartitionBy].(time.Time)
+	if !ok {
+		return "day=unknown"
+	}
+	return "day=" + t.In(d.Location).Format("2006-01-02")
+}
+
+// write writes the rows through a temporary file or a streaming upload, so
+// readers never see a partially written file.
+func (d *ParquetDestination) write(dir, run string, rows []map[string]interface{}) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.parquet", d.FilePrefix, run))
+	for i := 1; ; i++ {
+		exists, err := d.exists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s-%d.parquet", d.FilePrefix, run, i))
+	}
+
+	file, err := fileio.Create(path, fileio.CompressionNone, d.Store)
+	if err != nil {
+		return "", err
+	}
+
+	writer := goparquet.NewWriter(file, d.Schema,
+		goparquet.Compression(d.Compression),
+		goparquet.MaxRowsPerRowGroup(d.RowGroupSize),
+	)
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			file.Abort()
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		file.Abort()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	if d.Store != nil {
+		return d.Store.URL(path), nil
+	}
+	return path, nil
+}
+
+func (d *ParquetDestination) exists(path string) (bool, error) {
+	if d.Store != nil {
+		return d.Store.Exists(strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	}
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// row converts the values of a record into the Go types of the schema.
+func (d *ParquetDestination) row(record map[string]interface{}) (map[string]interface{}, error) {
+	row := make(map[string]interface{}, len(d.Model.Columns))
+	for _, column := range d.Model.Columns {
+		value, err := Value(record[column.Name], column.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		if value != nil {
+			row[column.Name] = value
+		}
+	}
+	return row, nil
+}
+
+// Value converts a record value into the Go type written for the column.
+// It goes through convert.Value like the other destinations, so numbers
+// decoded from JSON and numeric strings are accepted, and then narrows ints
+// to int32 and datetimes to UTC.
+func Value(value interface{}, columnType models.ColumnType) (interface{}, error) {
+	switch v := value.(type) {
+	case int32:
+		value = int64(v)
+	case []byte:
+		value = string(v)
+	case string:
+		if v == "" && columnType != models.String {
+			value = nil
+		}
+	}
+
+	converted, err := convert.Value(value, columnType, convert.Options{DateLayouts: []string{time.RFC3339Nano}})
+	if err != nil {
+		return nil, err
+	}
+	switch v := converted.(type) {
+	case nil:
+		if columnType == models.DateTime {
+			return nil, errors.New("value is required")
+		}
+		return nil, nil
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("%d out of range for int", v)
+		}
+		return int32(v), nil
+	case time.Time:
+		return v.UTC(), nil
+	}
+	return converted, nil
+}
+
+// RunSchema has nothing to create, the schema is part of every file.
+func (d *ParquetDestination) RunSchema() error {
+	log.Info("parquet destination has no schema to create")
+	return nil
+}
+
+func (d *ParquetDestination) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterDestination("parquet", func() plugins.Destination {
+		return &ParquetDestination{}
+	})
+}
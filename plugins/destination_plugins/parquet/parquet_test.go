@@ -0,0 +1,243 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/objstore/s3test"
+	goparquet "github.com/parquet-go/parquet-go"
+)
+
+var sensorModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "mandant", Type: models.Int},
+		{Name: "time", Type: models.DateTime},
+		{Name: "sensor", Type: models.String},
+		{Name: "value", Type: models.Float},
+		{Name: "ok", Type: models.Bool},
+		{Name: "meta", Type: models.JSON},
+	},
+	Unique: []string{"mandant", "time", "sensor"},
+}
+
+func TestStoreData(t *testing.T) {
+	dir := t.TempDir()
+	d := &ParquetDestination{}
+	err := d.Init(map[string]interface{}{
+		"path":           dir,
+		"partition_by":   "time",
+		"compression":    "zstd",
+		"row_group_size": 2,
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	data := []map[string]interface{}{
+		{"mandant": 1, "time": time.Date(2024, 9, 1, 0, 30, 0, 0, berlin), "sensor": "a", "value": 1.5, "ok": true, "meta": `{"x":1}`},
+		{"mandant": 1, "time": time.Date(2024, 9, 1, 23, 0, 0, 0, berlin), "sensor": "b", "value": nil},
+		{"mandant": 1, "time": time.Date(2024, 9, 1, 23, 30, 0, 0, berlin), "sensor": "c", "value": 2},
+		{"mandant": 2, "time": time.Date(2024, 9, 2, 8, 0, 0, 0, berlin), "sensor": "a", "value": 3.0},
+		{"mandant": 3, "time": nil, "sensor": "a"},
+		{"mandant": "x", "time": time.Date(2024, 9, 2, 8, 0, 0, 0, berlin), "sensor": "a"},
+	}
+	success, errored, err := d.StoreData(data)
+	if err != nil {
+		t.Fatalf("StoreData() error = %v", err)
+	}
+	if success != 4 || errored != 2 {
+		t.Errorf("StoreData() = %d, %d, want 4, 2", success, errored)
+	}
+
+	want := map[string]int64{"day=2024-09-01": 3, "day=2024-09-02": 1}
+	for partition, rows := range want {
+		files, _ := filepath.Glob(filepath.Join(dir, partition, "part-*.parquet"))
+		if len(files) != 1 {
+			t.Fatalf("expected one file in %s, got %v", partition, files)
+		}
+		file, err := os.Open(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		info, _ := file.Stat()
+		pf, err := goparquet.OpenFile(file, info.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pf.NumRows() != rows {
+			t.Errorf("%s has %d rows, want %d", partition, pf.NumRows(), rows)
+		}
+		if partition == "day=2024-09-01" && len(pf.RowGroups()) != 2 {
+			t.Errorf("expected 2 row groups, got %d", len(pf.RowGroups()))
+		}
+	}
+
+	// a second run adds files instead of replacing them
+	if _, _, err := d.StoreData(data[:1]); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "day=2024-09-01", "*.parquet"))
+	if len(files) != 2 {
+		t.Errorf("expected 2 files after the second run, got %v", files)
+	}
+}
+
+func TestStoreDataRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	d := &ParquetDestination{}
+	if err := d.Init(map[string]interface{}{"path": dir, "file_prefix": "ticks", "partition_by": "none"}, sensorModel); err != nil {
+		t.Fatal(err)
+	}
+	if d.PartitionBy != "" {
+		t.Errorf("PartitionBy = %q, want no partitioning", d.PartitionBy)
+	}
+	ts := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	if _, _, err := d.StoreData([]map[string]interface{}{
+		{"mandant": 1, "time": ts, "sensor": "a", "value": 1.5, "ok": true, "meta": map[string]interface{}{"x": 1}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "ticks-*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %v", files)
+	}
+	file, _ := os.Open(files[0])
+	defer file.Close()
+	reader := goparquet.NewReader(file)
+	row := map[string]interface{}{}
+	if err := reader.Read(&row); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if row["sensor"] != "a" || row["value"] != 1.5 || row["ok"] != true || fmt.Sprint(row["meta"]) != "map[x:1]" {
+		t.Errorf("unexpected row: %#v", row)
+	}
+}
+
+func TestStoreDataS3(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	d := &ParquetDestination{}
+	err := d.Init(map[string]interface{}{
+		"path":         "lake/sensors",
+		"partition_by": "time",
+		"s3": map[string]interface{}{
+			"endpoint":   server.URL,
+			"region":     "eu-central-1",
+			"bucket":     "archive",
+			"access_key": map[string]interface{}{"value": "minio"},
+			"secret_key": map[string]interface{}{"value": "minio123"},
+			"path_style": true,
+		},
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	ts := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	data := []map[string]interface{}{{"mandant": 1, "time": ts, "sensor": "a", "value": 1.5}}
+	for i := 0; i < 2; i++ {
+		if _, _, err := d.StoreData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := server.Keys("archive")
+	if len(keys) != 2 {
+		t.Fatalf("expected two objects, got %v", keys)
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "lake/sensors/day=2024-09-01/part-") {
+			t.Errorf("unexpected key %s", key)
+		}
+		object := server.Get("archive", key)
+		pf, err := goparquet.OpenFile(bytes.NewReader(object.Data), int64(len(object.Data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pf.NumRows() != 1 {
+			t.Errorf("%s has %d rows, want 1", key, pf.NumRows())
+		}
+	}
+}
+
+func TestDefaultPartition(t *testing.T) {
+	d := &ParquetDestination{}
+	if err := d.Init(map[string]interface{}{"path": "/tmp"}, sensorModel); err != nil {
+		t.Fatal(err)
+	}
+	if d.PartitionBy != "time" {
+		t.Errorf("PartitionBy = %q, want time", d.PartitionBy)
+	}
+
+	noTime := &models.Model{Columns: []models.Column{{Name: "sensor", Type: models.String}}}
+	d = &ParquetDestination{}
+	if err := d.Init(map[string]interface{}{"path": "/tmp"}, noTime); err != nil {
+		t.Fatal(err)
+	}
+	if d.PartitionBy != "" {
+		t.Errorf("PartitionBy = %q without datetime column", d.PartitionBy)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []map[string]interface{}{
+		{},
+		{"path": "/tmp", "partition_by": "sensor"},
+		{"path": "/tmp", "partition_by": "missing"},
+		{"path": "/tmp", "compression": "lzo"},
+	}
+	for _, cfg := range tests {
+		if err := (&ParquetDestination{}).Init(cfg, sensorModel); err == nil {
+			t.Errorf("Init(%v) expected error", cfg)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		value      interface{}
+		columnType models.ColumnType
+		want       interface{}
+		wantErr    bool
+	}{
+		{json.Number("42"), models.Int, int32(42), false},
+		{"42", models.BigInt, int64(42), false},
+		{float64(7), models.BigInt, int64(7), false},
+		{int32(3), models.Int, int32(3), false},
+		{json.Number("1.5"), models.Float, 1.5, false},
+		{"", models.Int, nil, false},
+		{int64(math.MaxInt32 + 1), models.Int, nil, true},
+		{7.5, models.BigInt, nil, true},
+		{time.Date(2024, 9, 1, 14, 0, 0, 0, berlin), models.DateTime, time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2024-09-01T14:00:00+02:00", models.DateTime, time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC), false},
+		{nil, models.DateTime, nil, true},
+		{map[string]interface{}{"a": 1}, models.JSON, `{"a":1}`, false},
+		{true, models.Bool, true, false},
+	}
+	for _, tt := range tests {
+		got, err := Value(tt.value, tt.columnType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Value(%#v, %s) error = %v, wantErr %v", tt.value, tt.columnType, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Value(%#v, %s) = %#v, want %#v", tt.value, tt.columnType, got, tt.want)
+		}
+	}
+}
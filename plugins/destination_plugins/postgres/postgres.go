@@ -0,0 +1,399 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/sqlinsert"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	_ "github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+type partitionConfig struct {
+	// Column is the datetime column the table is partitioned by.
+	Column string `yaml:"column"`
+	// Interval is day, week, month (default) or year.
+	Interval string `yaml:"interval"`
+	// Default adds a default partition for rows outside of the created
+	// partitions.
+	Default bool `yaml:"default"`
+}
+
+type postgresConfig struct {
+	ConnEnv   string           `yaml:"conn_env"`
+	Schema    string           `yaml:"schema"`
+	Table     string           `yaml:"table"`
+	BatchSize int              `yaml:"batch_size"`
+	Partition *partitionConfig `yaml:"partition"`
+	Timezone  string           `yaml:"timezone"`
+}
+
+// Partitioning describes a table partitioned by range of a datetime column.
+// The partitions are created while storing for the intervals of the
+// records.
+type Partitioning struct {
+	Column   string
+	Interval string
+	Default  bool
+	Location *time.Location
+}
+
+// PostgresDestination upserts the records into a PostgreSQL table. Unlike
+// timescaledb it makes no assumptions about extensions, tables can live in
+// any schema and may be partitioned natively.
+type PostgresDestination struct {
+	Model     *models.Model
+	DB        *sql.DB
+	Schema    string
+	Table     string
+	BatchSize int
+	Partition *Partitioning
+	Retry     *retry.Policy
+
+	// partitions holds the names of the partitions created in this run.
+	partitions map[string]bool
+}
+
+func (d *PostgresDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := postgresConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid postgres config: %v", err)
+	}
+	if c.Table == "" {
+		return errors.New("table is required")
+	}
+	d.Table = c.Table
+	d.Schema = c.Schema
+	if d.Schema == "" {
+		d.Schema = "public"
+	}
+	if c.BatchSize > 0 {
+		d.BatchSize = c.BatchSize
+	}
+
+	if c.Partition != nil {
+		partition, err := parsePartition(*c.Partition, c.Timezone, model)
+		if err != nil {
+			return err
+		}
+		d.Partition = partition
+	}
+	d.partitions = make(map[string]bool)
+
+	policy, err := retry.ParsePolicy(cfg)
+	if err != nil {
+		return err
+	}
+	d.Retry = policy
+
+	if c.ConnEnv == "" {
+		c.ConnEnv = "POSTGRES_CONN_STR"
+	}
+	connStr := os.Getenv(c.ConnEnv)
+	if connStr == "" {
+		return fmt.Errorf("%s environment variable is required", c.ConnEnv)
+	}
+	db, err := sql.Open("postgres", withSSLMode(connStr))
+	if err != nil {
+		return fmt.Errorf("unable to connect to database: %v", err)
+	}
+	d.DB = db
+	return nil
+}
+
+func parsePartition(c partitionConfig, timezone string, model *models.Model) (*Partitioning, error) {
+	if c.Column == "" {
+		return nil, errors.New("partition column is required")
+	}
+	found := false
+	for _, column := range model.Columns {
+		if column.Name == c.Column {
+			if column.Type != models.DateTime {
+				return nil, fmt.Errorf("partition column %s must be a datetime column", c.Column)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("partition column %s is not a model column", c.Column)
+	}
+	// unique constraints of partitioned tables must include the partition key
+	if len(model.Unique) > 0 && !slices.Contains(model.Unique, c.Column) {
+		return nil, fmt.Errorf("partition column %s must be part of the unique key", c.Column)
+	}
+
+	p := &Partitioning{Column: c.Column, Interval: c.Interval, Default: c.Default}
+	switch p.Interval {
+	case "":
+		p.Interval = IntervalMonth
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	default:
+		return nil, fmt.Errorf("invalid partition interval: %s", c.Interval)
+	}
+
+	var err error
+	if p.Location, err = convert.ParseLocation(timezone); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// withSSLMode disables TLS unless the connection string configures it.
+func withSSLMode(connStr string) string {
+	if strings.Contains(connStr, "sslmode") {
+		return connStr
+	}
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		if strings.Contains(connStr, "?") {
+			return connStr + "&sslmode=disable"
+		}
+		return connStr + "?sslmode=disable"
+	}
+	return connStr + " sslmode=disable"
+}
+
+func (d *PostgresDestination) Close() error {
+	if d.DB == nil {
+		return nil
+	}
+	return d.DB.Close()
+}
+
+func (d *PostgresDestination) getSQLType(columnType models.ColumnType) string {
+	switch columnType {
+	case models.String:
+		return "TEXT"
+	case models.BigInt:
+		return "BIGINT"
+	case models.Float:
+		return "DOUBLE PRECISION"
+	case models.DateTime:
+		return "TIMESTAMPTZ NOT NULL"
+	case models.DateTimeNullable:
+		return "TIMESTAMPTZ"
+	case models.Int:
+		return "INTEGER"
+	case models.Bool:
+		return "BOOLEAN"
+	case models.JSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+// qualified returns the quoted schema qualified name of a table.
+func (d *PostgresDestination) qualified(table string) string {
+	return tmpl.SQLIdent(d.Schema) + "." + tmpl.SQLIdent(table)
+}
+
+func (d *PostgresDestination) CreateSchema() ([]string, error) {
+	queries := []string{}
+
+	if d.Schema != "public" {
+		queries = append(queries, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", tmpl.SQLIdent(d.Schema)))
+	}
+
+	var stm strings.Builder
+	stm.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", d.qualified(d.Table)))
+	for i, column := range d.Model.Columns {
+		stm.WriteString(fmt.Sprintf("    %s %s", tmpl.SQLIdent(column.Name), d.getSQLType(column.Type)))
+		if i < len(d.Model.Columns)-1 || len(d.Model.Unique) > 0 {
+			stm.WriteString(",")
+		}
+		stm.WriteString("\n")
+	}
+	if len(d.Model.Unique) > 0 {
+		stm.WriteString(fmt.Sprintf("    PRIMARY KEY (%s)\n", sqlinsert.IdentList(d.Model.Unique)))
+	}
+	stm.WriteString(")")
+	if d.Partition != nil {
+		stm.WriteString(fmt.Sprintf(" PARTITION BY RANGE (%s)", tmpl.SQLIdent(d.Partition.Column)))
+	}
+	stm.WriteString(";\n")
+	queries = append(queries, stm.String())
+
+	if d.Partition != nil && d.Partition.Default {
+		queries = append(queries, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT;",
+			d.qualified(d.Table+"_default"), d.qualified(d.Table)))
+	}
+
+	if d.Model.Description != "" {
+		queries = append(queries, fmt.Sprintf("COMMENT ON TABLE %s IS %s;",
+			d.qualified(d.Table), tmpl.SQLString(d.Model.Description)))
+	}
+	for _, column := range d.Model.Columns {
+		if column.Description == "" {
+			continue
+		}
+		queries = append(queries, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;",
+			d.qualified(d.Table), tmpl.SQLIdent(column.Name), tmpl.SQLString(column.Description)))
+	}
+
+	return queries, nil
+}
+
+func (d *PostgresDestination) RunSchema() error {
+	queries, err := d.CreateSchema()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range queries {
+		log.WithFields(log.Fields{
+			"query": query,
+		}).Debug("Running schema queries")
+		if _, err := d.DB.Exec(query); err != nil {
+			return fmt.Errorf("error running schema query: %v", err)
+		}
+	}
+	return nil
+}
+
+// Bounds returns the start and end of the partition interval holding t.
+// Weeks start on Monday.
+func (p *Partitioning) Bounds(t time.Time) (time.Time, time.Time) {
+	t = t.In(p.Location)
+	switch p.Interval {
+	case IntervalDay:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(0, 0, 1)
+	case IntervalWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		start := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(0, 0, 7)
+	case IntervalYear:
+		start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(1, 0, 0)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// Name returns the partition table name for the interval starting at start,
+// e.g. orders_p202409 for monthly partitions.
+func (p *Partitioning) Name(table string, start time.Time) string {
+	switch p.Interval {
+	case IntervalDay, IntervalWeek:
+		return table + "_p" + start.Format("20060102")
+	case IntervalYear:
+		return table + "_p" + start.Format("2006")
+	default:
+		return table + "_p" + start.Format("200601")
+	}
+}
+
+// PartitionQueries returns the statements creating the partitions of the
+// records which were not created in this run yet.
+func (d *PostgresDestination) PartitionQueries(data []map[string]interface{}) []string {
+	if d.Partition == nil {
+		return nil
+	}
+
+	starts := make(map[string]time.Time)
+	for _, record := range data {
+		t, ok := record[d.Partition.Column].(time.Time)
+		if !ok {
+			continue
+		}
+		start, _ := d.Partition.Bounds(t)
+		name := d.Partition.Name(d.Table, start)
+		if !d.partitions[name] {
+			starts[name] = start
+		}
+	}
+
+	names := make([]string, 0, len(starts))
+	for name := range starts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queries := make([]string, 0, len(names))
+	for _, name := range names {
+		start, end := d.Partition.Bounds(starts[name])
+		queries = append(queries, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s);",
+			d.qualified(name), d.qualified(d.Table),
+			tmpl.SQLString(start.Format(time.RFC3339)), tmpl.SQLString(end.Format(time.RFC3339))))
+	}
+	return queries
+}
+
+// createPartitions creates the missing partitions for the records.
+func (d *PostgresDestination) createPartitions(data []map[string]interface{}) error {
+	for _, query := range d.PartitionQueries(data) {
+		log.WithField("query", query).Debug("Creating partition")
+		err := d.Retry.Do("postgres", func() error {
+			_, err := d.DB.Exec(query)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error creating partition: %v", err)
+		}
+	}
+	for _, record := range data {
+		if t, ok := record[d.Partition.Column].(time.Time); ok {
+			start, _ := d.Partition.Bounds(t)
+			d.partitions[d.Partition.Name(d.Table, start)] = true
+		}
+	}
+	return nil
+}
+
+// InsertQuery returns the insert statement, with unique columns existing
+// rows are updated.
+func (d *PostgresDestination) InsertQuery() string {
+	return d.inserter().Query()
+}
+
+func (d *PostgresDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	if d.Partition != nil {
+		if err := d.createPartitions(data); err != nil {
+			return 0, 0, err
+		}
+	}
+	return d.inserter().Store(data)
+}
+
+func (d *PostgresDestination) inserter() *sqlinsert.Inserter {
+	return &sqlinsert.Inserter{
+		Name:        "postgres",
+		DB:          d.DB,
+		Model:       d.Model,
+		Table:       d.qualified(d.Table),
+		BatchSize:   d.BatchSize,
+		Retry:       d.Retry,
+		Placeholder: sqlinsert.Dollar,
+		Upsert:      sqlinsert.OnConflict,
+	}
+}
+
+func init() {
+	plugins.RegisterDestination("postgres", func() plugins.Destination {
+		return &PostgresDestination{
+			BatchSize: 1000,
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/sqlinsert"
+	"github.com/Talk-Point/databridge/pkg/sqlitedb"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+type sqliteConfig struct {
+	Path      string `yaml:"path"`
+	Table     string `yaml:"table"`
+	BatchSize int    `yaml:"batch_size"`
+}
+
+// SQLiteDestination upserts the records into a table of a SQLite database
+// file. The file is created if it does not exist.
+type SQLiteDestination struct {
+	Model     *models.Model
+	DB        *sql.DB
+	Path      string
+	Table     string
+	BatchSize int
+	Retry     *retry.Policy
+}
+
+func (d *SQLiteDestination) Init(cfg map[string]interface{}, model *models.Model) error {
+	d.Model = model
+
+	c := sqliteConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid sqlite config: %v", err)
+	}
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+	if c.Table == "" {
+		return errors.New("table is required")
+	}
+	d.Path = c.Path
+	d.Table = c.Table
+	if c.BatchSize > 0 {
+		d.BatchSize = c.BatchSize
+	}
+
+	policy, err := retry.ParsePolicy(cfg)
+	if err != nil {
+		return err
+	}
+	d.Retry = policy
+
+	db, err := sqlitedb.Open(d.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", d.Path, err)
+	}
+	// a single connection serializes the writes of this process
+	db.SetMaxOpenConns(1)
+	d.DB = db
+	return nil
+}
+
+func (d *SQLiteDestination) Close() error {
+	if d.DB == nil {
+		return nil
+	}
+	return d.DB.Close()
+}
+
+// getSQLType returns the declared type of a column. SQLite has no datetime
+// storage class, the DATETIME declaration lets the driver scan the values
+// as time.Time again.
+func (d *SQLiteDestination) getSQLType(columnType models.ColumnType) string {
+	switch columnType {
+	case models.String:
+		return "TEXT"
+	case models.BigInt, models.Int:
+		return "INTEGER"
+	case models.Float:
+		return "REAL"
+	case models.DateTime:
+		return "DATETIME NOT NULL"
+	case models.DateTimeNullable:
+		return "DATETIME"
+	case models.Bool:
+		return "BOOLEAN"
+	case models.JSON:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func (d *SQLiteDestination) CreateSchema() ([]string, error) {
+	var stm strings.Builder
+
+	stm.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", tmpl.SQLIdent(d.Table)))
+	for i, column := range d.Model.Columns {
+		stm.WriteString(fmt.Sprintf("    %s %s", tmpl.SQLIdent(column.Name), d.getSQLType(column.Type)))
+		if i < len(d.Model.Columns)-1 || len(d.Model.Unique) > 0 {
+			stm.WriteString(",")
+		}
+		stm.WriteString("\n")
+	}
+	if len(d.Model.Unique) > 0 {
+		stm.WriteString(fmt.Sprintf("    PRIMARY KEY (%s)\n", sqlinsert.IdentList(d.Model.Unique)))
+	}
+	stm.WriteString(");\n")
+
+	return []string{stm.String()}, nil
+}
+
+func (d *SQLiteDestination) RunSchema() error {
+	queries, err := d.CreateSchema()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range queries {
+		log.WithFields(log.Fields{
+			"query": query,
+		}).Debug("Running schema queries")
+		if _, err := d.DB.Exec(query); err != nil {
+			return fmt.Errorf("error running schema query: %v", err)
+		}
+	}
+	return nil
+}
+
+// InsertQuery returns the insert statement, with unique columns existing
+// rows are updated.
+func (d *SQLiteDestination) InsertQuery() string {
+	return d.inserter().Query()
+}
+
+func (d *SQLiteDestination) StoreData(data []map[string]interface{}) (int, int, error) {
+	return d.inserter().Store(data)
+}
+
+func (d *SQLiteDestination) inserter() *sqlinsert.Inserter {
+	return &sqlinsert.Inserter{
+		Name:        "sqlite",
+		DB:          d.DB,
+		Model:       d.Model,
+		Table:       tmpl.SQLIdent(d.Table),
+		BatchSize:   d.BatchSize,
+		Retry:       d.Retry,
+		Placeholder: sqlinsert.Question,
+		Upsert:      sqlinsert.OnConflict,
+	}
+}
+
+func init() {
+	plugins.RegisterDestination("sqlite", func() plugins.Destination {
+		return &SQLiteDestination{
+			BatchSize: 1000,
+		}
+	})
+}
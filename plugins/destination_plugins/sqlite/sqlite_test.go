@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var orderModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "order_id", Type: models.BigInt},
+		{Name: "customer", Type: models.String},
+		{Name: "amount", Type: models.Float},
+		{Name: "paid", Type: models.Bool},
+		{Name: "meta", Type: models.JSON},
+	},
+	Unique: []string{"time", "order_id"},
+}
+
+func TestInsertQuery(t *testing.T) {
+	d := &SQLiteDestination{Model: orderModel, Table: "orders"}
+	want := `INSERT INTO "orders" ("time", "order_id", "customer", "amount", "paid", "meta") VALUES (?, ?, ?, ?, ?, ?)` +
+		` ON CONFLICT ("time", "order_id") DO UPDATE SET "customer" = EXCLUDED."customer", "amount" = EXCLUDED."amount", "paid" = EXCLUDED."paid", "meta" = EXCLUDED."meta";`
+	if got := d.InsertQuery(); got != want {
+		t.Errorf("InsertQuery() =\n%s\nwant\n%s", got, want)
+	}
+
+	d.Model = &models.Model{Columns: orderModel.Columns}
+	if got := d.InsertQuery(); strings.Contains(got, "ON CONFLICT") {
+		t.Errorf("InsertQuery() without unique columns = %s", got)
+	}
+}
+
+func TestStoreData(t *testing.T) {
+	d := &SQLiteDestination{BatchSize: 2}
+	path := filepath.Join(t.TempDir(), "orders.db")
+	if err := d.Init(map[string]interface{}{"path": path, "table": "orders"}, orderModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer d.Close()
+	if err := d.RunSchema(); err != nil {
+		t.Fatalf("RunSchema() error = %v", err)
+	}
+
+	ts := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	data := []map[string]interface{}{
+		{"time": ts, "order_id": int64(1), "customer": "Müller", "amount": 10.5, "paid": true, "meta": `{"a":1}`},
+		{"time": ts, "order_id": int64(2), "customer": "", "amount": nil, "paid": false, "meta": map[string]interface{}{"b": 2}},
+		{"time": ts, "order_id": int64(3), "customer": "Schmidt", "amount": 1.0, "paid": false, "meta": nil},
+	}
+	success, failed, err := d.StoreData(data)
+	if err != nil || success != 3 || failed != 0 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+
+	// the second run updates the existing row
+	data[0]["amount"] = 12.0
+	if success, failed, err = d.StoreData(data[:1]); err != nil || success != 1 || failed != 0 {
+		t.Fatalf("StoreData() = %d, %d, %v", success, failed, err)
+	}
+
+	var count int
+	if err := d.DB.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 rows, got %d", count)
+	}
+
+	var (
+		stored   time.Time
+		amount   float64
+		customer *string
+		meta     string
+	)
+	if err := d.DB.QueryRow(`SELECT time, amount FROM orders WHERE order_id = 1`).Scan(&stored, &amount); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Equal(ts) || amount != 12.0 {
+		t.Errorf("row 1 = %v, %v", stored, amount)
+	}
+	if err := d.DB.QueryRow(`SELECT customer, meta FROM orders WHERE order_id = 2`).Scan(&customer, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if customer != nil || meta != `{"b":2}` {
+		t.Errorf("row 2 = %v, %s", customer, meta)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"table": "orders"},
+		{"path": "orders.db"},
+	} {
+		d := &SQLiteDestination{}
+		if err := d.Init(cfg, orderModel); err == nil {
+			t.Errorf("Init(%v) expected error", cfg)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/retry"
 	"github.com/Talk-Point/databridge/plugins"
 	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
@@ -18,6 +19,7 @@ type TimescaleDBDestination struct {
 	Table     string
 	Schema    map[string]string // Column types
 	BatchSize int
+	Retry     *retry.Policy
 }
 
 func (d *TimescaleDBDestination) Init(config map[string]interface{}, model *models.Model) error {
@@ -36,6 +38,12 @@ func (d *TimescaleDBDestination) Init(config map[string]interface{}, model *mode
 	}
 	d.DB = db
 
+	policy, err := retry.ParsePolicy(config)
+	if err != nil {
+		return err
+	}
+	d.Retry = policy
+
 	// Optionally, create table if not exists using d.Schema
 	return nil
 }
@@ -58,6 +66,10 @@ func (d *TimescaleDBDestination) getSQLType(columnType models.ColumnType) string
 		return "TIMESTAMPTZ"
 	case models.Int:
 		return "INTEGER"
+	case models.Bool:
+		return "BOOLEAN"
+	case models.JSON:
+		return "JSONB"
 	default:
 		return "TEXT"
 	}
@@ -306,7 +318,7 @@ func (d *TimescaleDBDestination) StoreDataBatch(data []map[string]interface{}) (
 		batch = append(batch, values)
 
 		if len(batch) == batchSize {
-			err := executeBatch()
+			err := d.Retry.Do("timescaledb", executeBatch)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"idx":   idx,
@@ -327,7 +339,7 @@ func (d *TimescaleDBDestination) StoreDataBatch(data []map[string]interface{}) (
 	}
 
 	if len(batch) > 0 {
-		err := executeBatch()
+		err := d.Retry.Do("timescaledb", executeBatch)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"batch": batch,
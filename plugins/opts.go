@@ -0,0 +1,57 @@
+package plugins
+
+import (
+	"fmt"
+	"time"
+)
+
+// FetchOpts are the typed options passed to Source.FetchData.
+type FetchOpts struct {
+	Name     string
+	StartAt  time.Time
+	EndAt    time.Time
+	FilePath string
+	Vars     map[string]interface{}
+}
+
+// ParseFetchOpts reads the options of FetchData. All keys are optional, but
+// present keys must have the expected type.
+func ParseFetchOpts(opts map[string]interface{}) (FetchOpts, error) {
+	params := FetchOpts{Vars: map[string]interface{}{}}
+
+	for key, value := range opts {
+		var ok bool
+		switch key {
+		case "name":
+			params.Name, ok = value.(string)
+		case "start_at":
+			params.StartAt, ok = value.(time.Time)
+		case "end_at":
+			params.EndAt, ok = value.(time.Time)
+		case "file_path":
+			params.FilePath, ok = value.(string)
+		case "vars":
+			params.Vars, ok = value.(map[string]interface{})
+		default:
+			ok = true
+		}
+		if !ok {
+			return FetchOpts{}, fmt.Errorf("%s has invalid type %T", key, value)
+		}
+	}
+
+	return params, nil
+}
+
+// TemplateData returns the variables for query and path templates: the vars
+// together with name, start_at and end_at.
+func (o FetchOpts) TemplateData() map[string]interface{} {
+	data := make(map[string]interface{}, len(o.Vars)+3)
+	for key, value := range o.Vars {
+		data[key] = value
+	}
+	data["name"] = o.Name
+	data["start_at"] = o.StartAt
+	data["end_at"] = o.EndAt
+	return data
+}
@@ -22,6 +22,26 @@ type Destination interface {
 	Close() error
 }
 
+// Committer is implemented by sources that acknowledge their input once the
+// data was stored, e.g. file sources archiving the loaded files.
+type Committer interface {
+	Commit() error
+}
+
+// ErrorCounter is implemented by sources that drop records they can not
+// read, e.g. rows failing the transformation. The dropped records are
+// counted as errored.
+type ErrorCounter interface {
+	Errored() int
+}
+
+// Preparer is implemented by destinations that use the run parameters
+// passed to FetchData (name, window and vars), e.g. in path templates. It
+// is called before StoreData.
+type Preparer interface {
+	Prepare(opts map[string]interface{}) error
+}
+
 type SourceFactory func() Source
 type DestinationFactory func() Destination
 
@@ -0,0 +1,412 @@
+package camt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+// The structs follow the ISO 20022 element names. Namespaces are ignored,
+// so the versions 02 to 08 of camt.053 and camt.054 decode alike.
+
+type groupHeader struct {
+	MsgId   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type statement struct {
+	Id           string  `xml:"Id"`
+	ElctrncSeqNb string  `xml:"ElctrncSeqNb"`
+	CreDtTm      string  `xml:"CreDtTm"`
+	Acct         account `xml:"Acct"`
+	Ntry         []entry `xml:"Ntry"`
+}
+
+type account struct {
+	Id struct {
+		IBAN string `xml:"IBAN"`
+		Othr struct {
+			Id string `xml:"Id"`
+		} `xml:"Othr"`
+	} `xml:"Id"`
+	Ccy string `xml:"Ccy"`
+}
+
+func (a account) id() string {
+	if a.Id.IBAN != "" {
+		return a.Id.IBAN
+	}
+	return a.Id.Othr.Id
+}
+
+type amount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+type date struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+func (d date) value() string {
+	if d.Dt != "" {
+		return d.Dt
+	}
+	return d.DtTm
+}
+
+type bankTxCode struct {
+	Domn struct {
+		Cd   string `xml:"Cd"`
+		Fmly struct {
+			Cd        string `xml:"Cd"`
+			SubFmlyCd string `xml:"SubFmlyCd"`
+		} `xml:"Fmly"`
+	} `xml:"Domn"`
+	Prtry struct {
+		Cd string `xml:"Cd"`
+	} `xml:"Prtry"`
+}
+
+func (c bankTxCode) code() string {
+	if c.Domn.Cd != "" {
+		return strings.Join([]string{c.Domn.Cd, c.Domn.Fmly.Cd, c.Domn.Fmly.SubFmlyCd}, "-")
+	}
+	return c.Prtry.Cd
+}
+
+type entry struct {
+	NtryRef   string `xml:"NtryRef"`
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	RvslInd   bool   `xml:"RvslInd"`
+	// Sts is a code up to version 04 and an element with Cd afterwards
+	Sts struct {
+		Value string `xml:",chardata"`
+		Cd    string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookgDt     date       `xml:"BookgDt"`
+	ValDt       date       `xml:"ValDt"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	BkTxCd      bankTxCode `xml:"BkTxCd"`
+	NtryDtls    []struct {
+		TxDtls []transaction `xml:"TxDtls"`
+	} `xml:"NtryDtls"`
+	AddtlNtryInf string `xml:"AddtlNtryInf"`
+}
+
+type party struct {
+	Nm  string `xml:"Nm"`
+	Pty struct {
+		Nm string `xml:"Nm"`
+	} `xml:"Pty"`
+}
+
+func (p party) name() string {
+	if p.Nm != "" {
+		return p.Nm
+	}
+	return p.Pty.Nm
+}
+
+type agent struct {
+	FinInstnId struct {
+		BIC   string `xml:"BIC"`
+		BICFI string `xml:"BICFI"`
+	} `xml:"FinInstnId"`
+}
+
+func (a agent) bic() string {
+	if a.FinInstnId.BICFI != "" {
+		return a.FinInstnId.BICFI
+	}
+	return a.FinInstnId.BIC
+}
+
+type transaction struct {
+	Refs struct {
+		EndToEndId  string `xml:"EndToEndId"`
+		TxId        string `xml:"TxId"`
+		MndtId      string `xml:"MndtId"`
+		AcctSvcrRef string `xml:"AcctSvcrRef"`
+	} `xml:"Refs"`
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	AmtDtls   struct {
+		TxAmt struct {
+			Amt amount `xml:"Amt"`
+		} `xml:"TxAmt"`
+	} `xml:"AmtDtls"`
+	BkTxCd    bankTxCode `xml:"BkTxCd"`
+	RltdPties struct {
+		Dbtr     party   `xml:"Dbtr"`
+		DbtrAcct account `xml:"DbtrAcct"`
+		Cdtr     party   `xml:"Cdtr"`
+		CdtrAcct account `xml:"CdtrAcct"`
+	} `xml:"RltdPties"`
+	RltdAgts struct {
+		DbtrAgt agent `xml:"DbtrAgt"`
+		CdtrAgt agent `xml:"CdtrAgt"`
+	} `xml:"RltdAgts"`
+	RmtInf struct {
+		Ustrd []string `xml:"Ustrd"`
+		Strd  []struct {
+			CdtrRefInf struct {
+				Ref string `xml:"Ref"`
+			} `xml:"CdtrRefInf"`
+		} `xml:"Strd"`
+	} `xml:"RmtInf"`
+	AddtlTxInf string `xml:"AddtlTxInf"`
+}
+
+type camtConfig struct {
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	Timezone                  string `yaml:"timezone"`
+}
+
+// CAMTSource reads ISO 20022 bank statements (camt.053) and debit/credit
+// notifications (camt.054) into one record per transaction.
+type CAMTSource struct {
+	Model       *models.Model
+	Mapping     *mapping.Mapping
+	Convert     convert.Options
+	Compression fileio.CompressionOptions
+	*fileio.Files
+}
+
+func (s *CAMTSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := camtConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid camt config: %v", err)
+	}
+
+	var err error
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	s.Convert.DateLayouts = []string{"2006-01-02", time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	return s.Compression.Validate()
+}
+
+func (s *CAMTSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.ReadStreams(params.FilePath, s.Compression, s.read)
+}
+
+// read decodes one statement (Stmt) or notification (Ntfctn) at a time and
+// passes the transformed records to fn.
+func (s *CAMTSource) read(r io.Reader, fn func(record map[string]interface{})) error {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = fileio.CharsetReader
+
+	var header groupHeader
+	found := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "GrpHdr":
+			if err := decoder.DecodeElement(&header, &start); err != nil {
+				return err
+			}
+		case "Stmt", "Ntfctn", "Rpt":
+			var stmt statement
+			if err := decoder.DecodeElement(&stmt, &start); err != nil {
+				return err
+			}
+			found = true
+			for _, record := range flatten(header, stmt) {
+				transformedRecord, err := s.Transform(record)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"statement": stmt.Id,
+						"entry":     record["entry_ref"],
+						"error":     err,
+					}).Error("Error transforming record")
+					s.Files.Skip()
+					continue
+				}
+				fn(transformedRecord)
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no camt statement or notification found")
+	}
+	return nil
+}
+
+// signed prefixes debit amounts with a minus.
+func signed(value, indicator string) string {
+	value = strings.TrimSpace(value)
+	if indicator == "DBIT" && value != "" {
+		return "-" + value
+	}
+	return value
+}
+
+// flatten returns one record per transaction detail of the statement,
+// entries without details are a single record.
+func flatten(header groupHeader, stmt statement) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, ntry := range stmt.Ntry {
+		status := strings.TrimSpace(ntry.Sts.Value)
+		if ntry.Sts.Cd != "" {
+			status = ntry.Sts.Cd
+		}
+		base := map[string]interface{}{
+			"message_id":           header.MsgId,
+			"statement_id":         stmt.Id,
+			"sequence":             stmt.ElctrncSeqNb,
+			"statement_created":    stmt.CreDtTm,
+			"account_iban":         stmt.Acct.id(),
+			"account_currency":     stmt.Acct.Ccy,
+			"entry_ref":            ntry.NtryRef,
+			"amount":               signed(ntry.Amt.Value, ntry.CdtDbtInd),
+			"currency":             ntry.Amt.Ccy,
+			"credit_debit":         ntry.CdtDbtInd,
+			"reversal":             ntry.RvslInd,
+			"status":               status,
+			"booking_date":         ntry.BookgDt.value(),
+			"value_date":           ntry.ValDt.value(),
+			"account_servicer_ref": ntry.AcctSvcrRef,
+			"bank_tx_code":         ntry.BkTxCd.code(),
+			"additional_info":      ntry.AddtlNtryInf,
+		}
+
+		var details []transaction
+		for _, d := range ntry.NtryDtls {
+			details = append(details, d.TxDtls...)
+		}
+		if len(details) == 0 {
+			records = append(records, base)
+			continue
+		}
+
+		for _, tx := range details {
+			record := make(map[string]interface{}, len(base)+10)
+			for key, value := range base {
+				record[key] = value
+			}
+
+			indicator := ntry.CdtDbtInd
+			if tx.CdtDbtInd != "" {
+				indicator = tx.CdtDbtInd
+			}
+			// batch entries carry the amount of each transaction
+			if len(details) > 1 || tx.Amt.Value != "" {
+				amt := tx.Amt
+				if amt.Value == "" {
+					amt = tx.AmtDtls.TxAmt.Amt
+				}
+				if amt.Value != "" {
+					record["amount"] = signed(amt.Value, indicator)
+					record["currency"] = amt.Ccy
+				}
+			}
+			record["credit_debit"] = indicator
+			if code := tx.BkTxCd.code(); code != "" {
+				record["bank_tx_code"] = code
+			}
+			if tx.Refs.AcctSvcrRef != "" {
+				record["account_servicer_ref"] = tx.Refs.AcctSvcrRef
+			}
+			record["end_to_end_id"] = tx.Refs.EndToEndId
+			record["tx_id"] = tx.Refs.TxId
+			record["mandate_id"] = tx.Refs.MndtId
+			record["remittance_info"] = strings.Join(tx.RmtInf.Ustrd, " ")
+			if len(tx.RmtInf.Strd) > 0 {
+				record["creditor_reference"] = tx.RmtInf.Strd[0].CdtrRefInf.Ref
+			}
+
+			// the counterparty is the debtor of incoming and the creditor of
+			// outgoing payments
+			if indicator == "CRDT" {
+				record["counterparty_name"] = tx.RltdPties.Dbtr.name()
+				record["counterparty_iban"] = tx.RltdPties.DbtrAcct.id()
+				record["counterparty_bic"] = tx.RltdAgts.DbtrAgt.bic()
+			} else {
+				record["counterparty_name"] = tx.RltdPties.Cdtr.name()
+				record["counterparty_iban"] = tx.RltdPties.CdtrAcct.id()
+				record["counterparty_bic"] = tx.RltdAgts.CdtrAgt.bic()
+			}
+			if tx.AddtlTxInf != "" {
+				record["additional_info"] = tx.AddtlTxInf
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Transform maps a record onto the model columns and converts the values.
+func (s *CAMTSource) Transform(record map[string]interface{}) (map[string]interface{}, error) {
+	mapped, err := s.Mapping.Record(s.Model, record)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := mapped[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			mapped[column.Name] = nil
+			continue
+		}
+		if str, ok := value.(string); ok && str == "" && column.Type != models.String {
+			value = nil
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		mapped[column.Name] = data
+	}
+	return mapped, nil
+}
+
+func (s *CAMTSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("camt", func() plugins.Source {
+		return &CAMTSource{}
+	})
+}
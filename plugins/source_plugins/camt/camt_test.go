@@ -0,0 +1,115 @@
+package camt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var transactionModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "statement_id", Type: models.String},
+		{Name: "account_iban", Type: models.String},
+		{Name: "booking_date", Type: models.DateTime},
+		{Name: "value_date", Type: models.DateTimeNullable},
+		{Name: "amount", Type: models.Float},
+		{Name: "currency", Type: models.String},
+		{Name: "status", Type: models.String},
+		{Name: "bank_tx_code", Type: models.String},
+		{Name: "user_zahlungsreferenz", Type: models.String},
+		{Name: "remittance_info", Type: models.String},
+		{Name: "creditor_reference", Type: models.String},
+		{Name: "counterparty_name", Type: models.String},
+		{Name: "counterparty_iban", Type: models.String},
+		{Name: "counterparty_bic", Type: models.String},
+		{Name: "additional_info", Type: models.String},
+	},
+	Unique: []string{"statement_id", "user_zahlungsreferenz"},
+}
+
+func fetch(t *testing.T, file string) []map[string]interface{} {
+	t.Helper()
+	source := &CAMTSource{}
+	err := source.Init(map[string]interface{}{
+		"mapping": map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"user_zahlungsreferenz": "end_to_end_id"},
+		},
+	}, transactionModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	records, err := source.FetchData(map[string]interface{}{"file_path": file})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	return records
+}
+
+func check(t *testing.T, records []map[string]interface{}, want []map[string]interface{}) {
+	t.Helper()
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %d: %v", len(want), len(records), records)
+	}
+	for i := range want {
+		for column, value := range want[i] {
+			got := records[i][column]
+			if ts, ok := value.(time.Time); ok {
+				if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+					t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+			}
+		}
+	}
+}
+
+func TestCamt053(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	check(t, fetch(t, "testdata/camt053.xml"), []map[string]interface{}{
+		{
+			"statement_id": "STMT-0815", "account_iban": "DE02120300000000202051",
+			"booking_date": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin), "value_date": time.Date(2024, 9, 2, 0, 0, 0, 0, berlin),
+			"amount": 1190.0, "currency": "EUR", "status": "BOOK", "bank_tx_code": "PMNT-RCDT-ESCT",
+			"user_zahlungsreferenz": "RE-2024-0815", "remittance_info": "Rechnung RE-2024-0815 Kunde 4711",
+			"counterparty_name": "Müller GmbH", "counterparty_iban": "DE89370400440532013000", "counterparty_bic": "COBADEFFXXX",
+		},
+		{
+			"amount": -100.0, "user_zahlungsreferenz": "LF-1", "creditor_reference": "RF18539007547034",
+			"counterparty_name": "Lieferant A", "counterparty_iban": "DE75512108001245126199", "bank_tx_code": "PMNT-ICDT-ESCT",
+		},
+		{"amount": -50.0, "user_zahlungsreferenz": "LF-2", "counterparty_name": "Lieferant B", "counterparty_iban": ""},
+		{
+			"amount": -4.9, "bank_tx_code": "NCHG+808", "additional_info": "Kontoführung",
+			"user_zahlungsreferenz": nil, "counterparty_name": nil,
+		},
+	})
+}
+
+func TestCamt054(t *testing.T) {
+	check(t, fetch(t, "testdata/camt054.xml"), []map[string]interface{}{
+		{
+			"statement_id": "NTF-1-1", "booking_date": time.Date(2024, 9, 3, 7, 30, 0, 0, time.UTC),
+			"amount": 19.99, "status": "PDNG", "user_zahlungsreferenz": "ZR-42",
+			"remittance_info": "Zahlungsreferenz ZR-42", "counterparty_name": "Jürgen Schäfer",
+			"counterparty_iban": "DE44500105175407324931", "counterparty_bic": "INGDDEFFXXX",
+		},
+	})
+}
+
+func TestReadErrors(t *testing.T) {
+	source := &CAMTSource{}
+	if err := source.Init(map[string]interface{}{}, transactionModel); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{"<Document></Document>", "<Document><Stmt><Ntry>"} {
+		if err := source.read(strings.NewReader(input), func(map[string]interface{}) {}); err == nil {
+			t.Errorf("read(%q) expected error", input)
+		}
+	}
+}
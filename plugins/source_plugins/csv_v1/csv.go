@@ -1,46 +1,160 @@
 package csv
 
 import (
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
-	"strings"
-	"time"
 
+	"github.com/Talk-Point/databridge/config"
 	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/csvreader"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
 	"github.com/Talk-Point/databridge/plugins"
 	log "github.com/sirupsen/logrus"
 )
 
+type csvConfig struct {
+	fileio.TextOptions        `yaml:",inline"`
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	// File is the former name of path
+	File      string   `yaml:"file"`
+	Delimiter string   `yaml:"delimiter"`
+	Quote     *string  `yaml:"quote"`
+	Escape    *string  `yaml:"escape"`
+	Comment   string   `yaml:"comment"`
+	Trim      bool     `yaml:"trim"`
+	HeaderRow *int     `yaml:"header_row"`
+	Columns   []string `yaml:"columns"`
+}
+
 type CSVSource struct {
-	Model *models.Model
+	Model   *models.Model
+	Dialect csvreader.Dialect
+	Text    fileio.TextOptions
+	// HeaderRow is the 1 based record holding the column names, 0 when the
+	// file has no header and Columns are used instead.
+	HeaderRow   int
+	Columns     []string
+	Mapping     *mapping.Mapping
+	Compression fileio.CompressionOptions
+	Convert     convert.Options
+	*fileio.Files
 }
 
-func (s *CSVSource) Init(config map[string]interface{}, model *models.Model) error {
+func (s *CSVSource) Init(cfg map[string]interface{}, model *models.Model) error {
 	s.Model = model
+
+	c := csvConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid csv config: %v", err)
+	}
+	s.Text = c.TextOptions
+	if _, err := fileio.Encoding(s.Text.Encoding); err != nil {
+		return err
+	}
+
+	s.Dialect = csvreader.DefaultDialect()
+	var err error
+	if c.Delimiter != "" {
+		if s.Dialect.Delimiter, err = csvreader.ParseRune("delimiter", c.Delimiter); err != nil {
+			return err
+		}
+	}
+	if c.Quote != nil {
+		if s.Dialect.Quote, err = csvreader.ParseRune("quote", *c.Quote); err != nil {
+			return err
+		}
+		s.Dialect.Escape = s.Dialect.Quote
+	}
+	if c.Escape != nil {
+		if s.Dialect.Escape, err = csvreader.ParseRune("escape", *c.Escape); err != nil {
+			return err
+		}
+	}
+	if s.Dialect.Comment, err = csvreader.ParseRune("comment", c.Comment); err != nil {
+		return err
+	}
+	s.Dialect.Trim = c.Trim
+
+	s.Convert.DateLayouts = []string{"2006-01-02T15:04:05"}
+	if s.Convert.Location, err = convert.ParseLocation(""); err != nil {
+		return err
+	}
+
+	s.Columns = c.Columns
+	switch {
+	case c.HeaderRow != nil:
+		if *c.HeaderRow < 0 {
+			return errors.New("header_row must not be negative")
+		}
+		s.HeaderRow = *c.HeaderRow
+	case len(c.Columns) > 0:
+		s.HeaderRow = 0
+	default:
+		s.HeaderRow = 1
+	}
+
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	if s.HeaderRow == 0 && len(s.Columns) == 0 && len(s.Mapping.Columns) == 0 {
+		return errors.New("columns or a mapping are required for files without header")
+	}
+
+	if c.FileOptions.Path == "" {
+		c.FileOptions.Path = c.File
+	}
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	if err := s.Compression.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (s *CSVSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
-	filepath, ok := opts["file_path"].(string)
-	if !ok {
-		return nil, errors.New("file_path is missing")
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	file, err := os.Open(filepath)
+	// The -file-path flag overrides the path of the config. Compressed
+	// files are unpacked, every zip member is read as its own CSV file.
+	return s.Files.ReadStreams(params.FilePath, s.Compression, func(r io.Reader, emit fileio.Emit) error {
+		records, err := s.read(r)
+		for _, record := range records {
+			emit(record)
+		}
+		return err
+	})
+}
+
+// read parses the CSV content and transforms every row into a record.
+func (s *CSVSource) read(r io.Reader) ([]map[string]interface{}, error) {
+	text, err := fileio.NewTextReader(r, s.Text)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	reader := csvreader.New(text, s.Dialect)
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable number of fields per record
-
-	// Read the header
-	header, err := reader.Read()
+	// Read the header, records before the header row are skipped
+	header := s.Columns
+	for i := 1; i <= s.HeaderRow; i++ {
+		row, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("error reading header: %v", err)
+		}
+		if i == s.HeaderRow && len(s.Columns) == 0 {
+			header = row
+		}
+	}
+	positions, err := s.Mapping.Resolve(s.Model, header)
 	if err != nil {
 		return nil, err
 	}
@@ -53,25 +167,26 @@ func (s *CSVSource) FetchData(opts map[string]interface{}) ([]map[string]interfa
 			break
 		}
 		if err != nil {
-			log.WithError(err).Error("Error reading CSV row")
-			continue
+			var parseErr *csvreader.ParseError
+			if errors.As(err, &parseErr) {
+				log.WithError(err).Error("Error reading CSV row")
+				s.Files.Skip()
+				continue
+			}
+			return nil, err
 		}
 
 		// Map row to record
-		record := make(map[string]interface{})
-		for i, value := range row {
-			if i < len(header) {
-				columnName := header[i]
-				record[columnName] = value
-			}
-		}
+		record := mapping.Row(positions, row)
 
 		transformedRecord, err := s.Transform(record)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"record": record,
+				"line":   reader.Line(),
 				"error":  err,
 			}).Error("Error transforming record")
+			s.Files.Skip()
 			continue
 		}
 
@@ -88,7 +203,7 @@ func (s *CSVSource) Transform(record map[string]interface{}) (map[string]interfa
 			if !ok {
 				return nil, fmt.Errorf("value for column %s is not a string", column.Name)
 			}
-			data, err := convert(strVal, column.Type)
+			data, err := convert.String(strVal, column.Type, s.Convert)
 			if err != nil {
 				return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
 			}
@@ -101,56 +216,6 @@ func (s *CSVSource) Transform(record map[string]interface{}) (map[string]interfa
 	return record, nil
 }
 
-func convert(value string, columnType models.ColumnType) (interface{}, error) {
-	switch columnType {
-	case models.String:
-		return value, nil
-	case models.BigInt:
-		intVal, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		return intVal, nil
-	case models.Float:
-		floatVal, err := strconv.ParseFloat(strings.Replace(value, ",", ".", -1), 64)
-		if err != nil {
-			return nil, err
-		}
-		return floatVal, nil
-	case models.DateTime:
-		loc, err := time.LoadLocation("Europe/Berlin")
-		if err != nil {
-			return nil, err
-		}
-		dateVal, err := time.ParseInLocation("2006-01-02T15:04:05", value, loc)
-		if err != nil {
-			return nil, err
-		}
-		return dateVal, nil
-	case models.DateTimeNullable:
-		if value == "" {
-			return nil, nil
-		}
-		loc, err := time.LoadLocation("Europe/Berlin")
-		if err != nil {
-			return nil, err
-		}
-		dateVal, err := time.ParseInLocation("2006-01-02T15:04:05", value, loc)
-		if err != nil {
-			return nil, err
-		}
-		return dateVal, nil
-	case models.Int:
-		intVal, err := strconv.Atoi(value)
-		if err != nil {
-			return nil, err
-		}
-		return intVal, nil
-	default:
-		return nil, fmt.Errorf("invalid column type: %s", columnType)
-	}
-}
-
 func (s *CSVSource) Close() error {
 	return nil
 }
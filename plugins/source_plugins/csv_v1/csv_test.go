@@ -0,0 +1,263 @@
+package csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var sensorModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "mandant", Type: models.Int},
+		{Name: "time", Type: models.DateTime},
+		{Name: "sensor", Type: models.String},
+		{Name: "value", Type: models.Float},
+	},
+	Unique: []string{"mandant", "time", "sensor"},
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		input   string
+		file    string
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "defaults with utf-8 bom",
+			config: map[string]interface{}{},
+			file:   "testdata/bom.csv",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "temperature", "value": 22.5},
+			},
+		},
+		{
+			name: "windows-1252 semicolon with preamble",
+			config: map[string]interface{}{
+				"delimiter":  ";",
+				"encoding":   "windows-1252",
+				"skip_lines": 2,
+			},
+			file: "testdata/windows1252.csv",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "Kühlraum", "value": 22.5},
+				{"mandant": 2, "sensor": "Straße; Außen", "value": -3.25},
+			},
+		},
+		{
+			name: "header row after preamble records",
+			config: map[string]interface{}{
+				"header_row": 2,
+				"trim":       true,
+			},
+			input: "report,generated\nmandant , time , sensor , value\n 1 , 2024-09-01T12:00:00 , a , 1.5\n",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "a", "value": 1.5},
+			},
+		},
+		{
+			name: "explicit columns without header",
+			config: map[string]interface{}{
+				"delimiter": "|",
+				"quote":     "'",
+				"escape":    `\`,
+				"comment":   "#",
+				"columns":   []interface{}{"mandant", "time", "sensor", "value"},
+			},
+			input: "# no header\n1|2024-09-01T12:00:00|'it\\'s'|2\n",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "it's", "value": 2.0},
+			},
+		},
+		{
+			name: "mapping with case insensitive names and index",
+			config: map[string]interface{}{
+				"mapping": map[interface{}]interface{}{
+					"case_insensitive": true,
+					"strict":           true,
+					"columns": map[interface{}]interface{}{
+						"sensor": "Messstelle",
+						"value":  4,
+					},
+				},
+			},
+			input: "MANDANT,Time,messstelle,Wert\n1,2024-09-01T12:00:00,a,1.5\n",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "a", "value": 1.5},
+			},
+		},
+		{
+			name: "mapping without header",
+			config: map[string]interface{}{
+				"header_row": 0,
+				"mapping": map[interface{}]interface{}{
+					"columns": map[interface{}]interface{}{"mandant": 1, "time": 2, "sensor": 3, "value": 4},
+				},
+			},
+			input: "1,2024-09-01T12:00:00,a,1.5\n",
+			want: []map[string]interface{}{
+				{"mandant": 1, "sensor": "a", "value": 1.5},
+			},
+		},
+		{
+			name:    "no header without columns",
+			config:  map[string]interface{}{"header_row": 0},
+			wantErr: true,
+		},
+		{
+			name:    "invalid delimiter",
+			config:  map[string]interface{}{"delimiter": ";;"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid encoding",
+			config:  map[string]interface{}{"encoding": "klingon"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &CSVSource{}
+			err := source.Init(tt.config, sensorModel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			input := tt.input
+			if tt.file != "" {
+				data, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				input = string(data)
+			}
+
+			records, err := source.read(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d: %v", len(tt.want), len(records), records)
+			}
+			for i, want := range tt.want {
+				for column, value := range want {
+					if records[i][column] != value {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, records[i][column], value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFetchDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"2024-09-01.csv": "mandant,time,sensor,value\n1,2024-09-01T12:00:00,a,1.5\n",
+		"2024-09-02.csv": "mandant,time,sensor,value\n1,2024-09-02T12:00:00,a,2.5\n",
+		"broken.csv":     "",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := &CSVSource{}
+	err := source.Init(map[string]interface{}{
+		"file":        dir,
+		"pattern":     "*.csv",
+		"archive_dir": filepath.Join(dir, "archive"),
+		"error_dir":   filepath.Join(dir, "error"),
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	records, err := source.FetchData(map[string]interface{}{"file_path": ""})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if len(records) != 2 || records[0]["value"] != 1.5 || records[1]["value"] != 2.5 {
+		t.Fatalf("unexpected records: %v", records)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "error", "broken.csv")); err != nil {
+		t.Errorf("expected broken.csv in error dir: %v", err)
+	}
+
+	if err := source.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "archive")); len(entries) != 2 {
+		t.Errorf("expected 2 archived files, got %d", len(entries))
+	}
+}
+
+func TestFetchDataBadRow(t *testing.T) {
+	dir := t.TempDir()
+	content := "mandant,time,sensor,value\n1,2024-09-01T12:00:00,a,1.5\n1,2024-09-01T12:05:00,a,kaputt\n"
+	if err := os.WriteFile(filepath.Join(dir, "ticks.csv"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "state.json")
+
+	source := &CSVSource{}
+	err := source.Init(map[string]interface{}{
+		"path":        dir,
+		"archive_dir": filepath.Join(dir, "archive"),
+		"state_file":  state,
+	}, sensorModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	// without error_dir the file with the bad row fails the run
+	if _, err := source.FetchData(map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "1 records could not be read") {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if source.Errored() != 1 {
+		t.Errorf("Errored() = %d, want 1", source.Errored())
+	}
+	if err := source.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ticks.csv")); err != nil {
+		t.Errorf("ticks.csv was archived: %v", err)
+	}
+	if data, _ := os.ReadFile(state); strings.Contains(string(data), "ticks.csv") {
+		t.Errorf("ticks.csv was recorded in the state: %s", data)
+	}
+}
+
+func TestFetchDataGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ticks.csv.gz")
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("mandant,time,sensor,value\n1,2024-09-01T12:00:00,a,1.5\n"))
+	w.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &CSVSource{}
+	if err := source.Init(map[string]interface{}{}, sensorModel); err != nil {
+		t.Fatal(err)
+	}
+	records, err := source.FetchData(map[string]interface{}{"file_path": path})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if len(records) != 1 || records[0]["value"] != 1.5 {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
@@ -0,0 +1,422 @@
+package datev
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/csvreader"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+// Header is the metadata of the first line of a DATEV file.
+type Header struct {
+	Kennzeichen   string
+	Version       string
+	Kategorie     int
+	Format        string
+	FormatVersion string
+	ErzeugtAm     *time.Time
+	Berater       string
+	Mandant       string
+	WJBeginn      *time.Time
+	DatumVom      *time.Time
+	DatumBis      *time.Time
+	Bezeichnung   string
+	Waehrung      string
+}
+
+// Columns returns the header fields as the extra columns of every record.
+func (h Header) Columns() map[string]interface{} {
+	columns := map[string]interface{}{
+		"datev_kennzeichen":    h.Kennzeichen,
+		"datev_version":        h.Version,
+		"datev_kategorie":      h.Kategorie,
+		"datev_format":         h.Format,
+		"datev_format_version": h.FormatVersion,
+		"datev_berater":        h.Berater,
+		"datev_mandant":        h.Mandant,
+		"datev_bezeichnung":    h.Bezeichnung,
+		"datev_waehrung":       h.Waehrung,
+	}
+	for name, value := range map[string]*time.Time{
+		"datev_erzeugt_am": h.ErzeugtAm,
+		"datev_wj_beginn":  h.WJBeginn,
+		"datev_datum_vom":  h.DatumVom,
+		"datev_datum_bis":  h.DatumBis,
+	} {
+		if value != nil {
+			columns[name] = *value
+		} else {
+			columns[name] = nil
+		}
+	}
+	return columns
+}
+
+// standardColumns are the names of the Buchungsstapel columns, other
+// columns are named by their normalized header.
+var standardColumns = map[string]string{
+	"Umsatz (ohne Soll/Haben-Kz)":    "umsatz",
+	"Soll/Haben-Kennzeichen":         "soll_haben",
+	"WKZ Umsatz":                     "wkz_umsatz",
+	"Kurs":                           "kurs",
+	"Basis-Umsatz":                   "basis_umsatz",
+	"WKZ Basis-Umsatz":               "wkz_basis_umsatz",
+	"Konto":                          "konto",
+	"Gegenkonto (ohne BU-Schlüssel)": "gegenkonto",
+	"BU-Schlüssel":                   "bu_schluessel",
+	"Belegdatum":                     "belegdatum",
+	"Belegfeld 1":                    "belegfeld1",
+	"Belegfeld 2":                    "belegfeld2",
+	"Skonto":                         "skonto",
+	"Buchungstext":                   "buchungstext",
+	"Postensperre":                   "postensperre",
+	"Diverse Adressnummer":           "diverse_adressnummer",
+	"Geschäftspartnerbank":           "geschaeftspartnerbank",
+	"Sachverhalt":                    "sachverhalt",
+	"Zinssperre":                     "zinssperre",
+	"Beleglink":                      "beleglink",
+	"KOST1 - Kostenstelle":           "kost1",
+	"KOST2 - Kostenstelle":           "kost2",
+	"Kost-Menge":                     "kost_menge",
+	"EU-Land u. UStID":               "eu_ustid",
+	"EU-Steuersatz":                  "eu_steuersatz",
+	"Abw. Versteuerungsart":          "abw_versteuerungsart",
+	"Leistungsdatum":                 "leistungsdatum",
+	"Datum Zuord. Steuerperiode":     "datum_steuerperiode",
+	"Fälligkeit":                     "faelligkeit",
+	"Generalumkehr (GU)":             "generalumkehr",
+	"Steuersatz":                     "steuersatz",
+	"Land":                           "land",
+	"Festschreibung":                 "festschreibung",
+}
+
+var (
+	umlauts  = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss")
+	nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ColumnName returns the column name of a DATEV header.
+func ColumnName(header string) string {
+	if name, ok := standardColumns[header]; ok {
+		return name
+	}
+	name := umlauts.Replace(strings.ToLower(strings.TrimSpace(header)))
+	return strings.Trim(nonAlnum.ReplaceAllString(name, "_"), "_")
+}
+
+type datevConfig struct {
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	Encoding                  string `yaml:"encoding"`
+	Timezone                  string `yaml:"timezone"`
+}
+
+// DATEVSource reads DATEV export files (EXTF or DTVF). The columns are named
+// by their standard names (e.g. umsatz, konto, belegdatum) and every record
+// carries the header metadata as datev_* columns. Betrag is the amount
+// signed by the Soll/Haben-Kennzeichen.
+type DATEVSource struct {
+	Model       *models.Model
+	Text        fileio.TextOptions
+	Mapping     *mapping.Mapping
+	Convert     convert.Options
+	Compression fileio.CompressionOptions
+	*fileio.Files
+}
+
+func (s *DATEVSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := datevConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid datev config: %v", err)
+	}
+	// DATEV files are ANSI encoded
+	s.Text.Encoding = c.Encoding
+	if s.Text.Encoding == "" {
+		s.Text.Encoding = "windows-1252"
+	}
+	if _, err := fileio.Encoding(s.Text.Encoding); err != nil {
+		return err
+	}
+
+	var err error
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	// Leistungsdatum and Fälligkeit are DDMMYYYY
+	s.Convert.DateLayouts = []string{"02.01.2006", "20060102", "02012006"}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	return s.Compression.Validate()
+}
+
+func (s *DATEVSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.ReadStreams(params.FilePath, s.Compression, func(r io.Reader, emit fileio.Emit) error {
+		records, err := s.read(r)
+		for _, record := range records {
+			emit(record)
+		}
+		return err
+	})
+}
+
+// read parses the header line, the column names and the records.
+func (s *DATEVSource) read(r io.Reader) ([]map[string]interface{}, error) {
+	text, err := fileio.NewTextReader(r, s.Text)
+	if err != nil {
+		return nil, err
+	}
+	dialect := csvreader.DefaultDialect()
+	dialect.Delimiter = ';'
+	reader := csvreader.New(text, dialect)
+
+	line, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading DATEV header: %v", err)
+	}
+	header, err := s.ParseHeader(line)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"format":  header.Format,
+		"berater": header.Berater,
+		"mandant": header.Mandant,
+	}).Debug("DATEV header")
+
+	names, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading column names: %v", err)
+	}
+	columns := make([]string, len(names))
+	for i, name := range names {
+		columns[i] = ColumnName(name)
+	}
+	extra := header.Columns()
+
+	var records []map[string]interface{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csvreader.ParseError
+			if errors.As(err, &parseErr) {
+				log.WithError(err).Error("Error reading DATEV row")
+				s.Files.Skip()
+				continue
+			}
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns)+len(extra)+1)
+		for name, value := range extra {
+			record[name] = value
+		}
+		for i, value := range row {
+			if i < len(columns) && columns[i] != "" {
+				record[columns[i]] = value
+			}
+		}
+
+		transformedRecord, err := s.Transform(record, header)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"record": record,
+				"line":   reader.Line(),
+				"error":  err,
+			}).Error("Error transforming record")
+			s.Files.Skip()
+			continue
+		}
+		records = append(records, transformedRecord)
+	}
+
+	return records, nil
+}
+
+// ParseHeader reads the metadata of the first line.
+func (s *DATEVSource) ParseHeader(fields []string) (Header, error) {
+	field := func(i int) string {
+		if i <= len(fields) {
+			return strings.TrimSpace(fields[i-1])
+		}
+		return ""
+	}
+
+	h := Header{
+		Kennzeichen:   field(1),
+		Version:       field(2),
+		Format:        field(4),
+		FormatVersion: field(5),
+		Berater:       field(11),
+		Mandant:       field(12),
+		Bezeichnung:   field(17),
+		Waehrung:      field(22),
+	}
+	if h.Kennzeichen != "EXTF" && h.Kennzeichen != "DTVF" {
+		return Header{}, fmt.Errorf("not a DATEV file, header starts with %q", h.Kennzeichen)
+	}
+	if kategorie := field(3); kategorie != "" {
+		var err error
+		if h.Kategorie, err = strconv.Atoi(kategorie); err != nil {
+			return Header{}, fmt.Errorf("invalid Datenkategorie %q", kategorie)
+		}
+	}
+
+	for _, f := range []struct {
+		index  int
+		layout string
+		target **time.Time
+	}{
+		{6, "20060102150405", &h.ErzeugtAm},
+		{13, "20060102", &h.WJBeginn},
+		{15, "20060102", &h.DatumVom},
+		{16, "20060102", &h.DatumBis},
+	} {
+		value := field(f.index)
+		if value == "" {
+			continue
+		}
+		// Erzeugt am has milliseconds appended
+		if len(value) > len(f.layout) {
+			value = value[:len(f.layout)]
+		}
+		t, err := time.ParseInLocation(f.layout, value, s.Convert.Location)
+		if err != nil {
+			return Header{}, fmt.Errorf("invalid date %q in DATEV header field %d", value, f.index)
+		}
+		*f.target = &t
+	}
+
+	return h, nil
+}
+
+// belegdatum reads the DDMM Belegdatum, the year is taken from the period
+// of the header. Dates before the start of the period belong to the next
+// year, e.g. for fiscal years not starting in January.
+func belegdatum(value string, h Header, loc *time.Location) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if len(value) == 3 {
+		value = "0" + value
+	}
+	if len(value) != 4 {
+		return value, nil
+	}
+	start := h.DatumVom
+	if start == nil {
+		start = h.WJBeginn
+	}
+	if start == nil {
+		return nil, fmt.Errorf("belegdatum %s without period in the header", value)
+	}
+	day, err := strconv.Atoi(value[:2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid belegdatum %s", value)
+	}
+	month, err := strconv.Atoi(value[2:])
+	if err != nil || month < 1 || month > 12 {
+		return nil, fmt.Errorf("invalid belegdatum %s", value)
+	}
+	year := start.Year()
+	if time.Month(month) < start.Month() {
+		year++
+	}
+	// time.Date would roll 3102 over into March
+	if last := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day(); day < 1 || day > last {
+		return nil, fmt.Errorf("invalid belegdatum %s", value)
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc), nil
+}
+
+// number converts a German formatted number (1.234,56) into the decimal
+// point notation.
+func number(value string) string {
+	if strings.Contains(value, ",") {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	return value
+}
+
+// Transform adds the signed amount and maps the record onto the model
+// columns.
+func (s *DATEVSource) Transform(record map[string]interface{}, h Header) (map[string]interface{}, error) {
+	if value, ok := record["belegdatum"].(string); ok {
+		date, err := belegdatum(strings.TrimSpace(value), h, s.Convert.Location)
+		if err != nil {
+			return nil, err
+		}
+		record["belegdatum"] = date
+	}
+	if umsatz, ok := record["umsatz"].(string); ok && umsatz != "" {
+		betrag := number(umsatz)
+		if sh, _ := record["soll_haben"].(string); strings.EqualFold(sh, "H") {
+			betrag = "-" + betrag
+		}
+		record["betrag"] = betrag
+	}
+
+	mapped, err := s.Mapping.Record(s.Model, record)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := mapped[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			mapped[column.Name] = nil
+			continue
+		}
+		if str, ok := value.(string); ok {
+			str = strings.TrimSpace(str)
+			if column.Type == models.Float || column.Type == models.Int || column.Type == models.BigInt {
+				str = number(str)
+			}
+			value = str
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		mapped[column.Name] = data
+	}
+	return mapped, nil
+}
+
+func (s *DATEVSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("datev", func() plugins.Source {
+		return &DATEVSource{}
+	})
+}
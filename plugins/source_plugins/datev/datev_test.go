@@ -0,0 +1,161 @@
+package datev
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var buchungModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "belegfeld1", Type: models.String},
+		{Name: "belegdatum", Type: models.DateTime},
+		{Name: "betrag", Type: models.Float},
+		{Name: "umsatz", Type: models.Float},
+		{Name: "konto", Type: models.Int},
+		{Name: "gegenkonto", Type: models.Int},
+		{Name: "buchungstext", Type: models.String},
+		{Name: "kostenstelle", Type: models.String},
+		{Name: "leistungsdatum", Type: models.DateTimeNullable},
+		{Name: "datev_berater", Type: models.String},
+		{Name: "datev_mandant", Type: models.Int},
+		{Name: "datev_datum_vom", Type: models.DateTime},
+		{Name: "datev_format", Type: models.String},
+	},
+	Unique: []string{"belegfeld1"},
+}
+
+func TestFetchData(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	source := &DATEVSource{}
+	err := source.Init(map[string]interface{}{
+		"mapping": map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"kostenstelle": "kost1"},
+		},
+	}, buchungModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	// the export holds a broken booking, FetchData fails the file
+	file, err := os.Open("testdata/EXTF_Buchungsstapel.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := source.read(file)
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", len(records), records)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"belegfeld1": "RE-2024-0815", "belegdatum": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin),
+			"betrag": 1190.0, "umsatz": 1190.0, "konto": 10000, "gegenkonto": 8400,
+			"buchungstext": "Müller GmbH", "kostenstelle": "4711",
+			"leistungsdatum": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin),
+			"datev_berater":  "29098", "datev_mandant": 55003, "datev_format": "Buchungsstapel",
+			"datev_datum_vom": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin),
+		},
+		{
+			"belegfeld1": "ER-77", "belegdatum": time.Date(2024, 9, 20, 0, 0, 0, 0, berlin),
+			"betrag": -49.99, "umsatz": 49.99, "buchungstext": "Büromaterial; Papier",
+			"kostenstelle": "", "leistungsdatum": nil,
+		},
+	}
+	for i := range want {
+		for column, value := range want[i] {
+			got := records[i][column]
+			if ts, ok := value.(time.Time); ok {
+				if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+					t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+			}
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	source := &DATEVSource{}
+	if err := source.Init(map[string]interface{}{}, buchungModel); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := source.ParseHeader(strings.Split(`EXTF;700;21;Buchungsstapel;13;20241005101530123;;RE;;;29098;55003;20240701;4;20240901;20240930;Buchungen September;;1;0;0;EUR`, ";"))
+	if err != nil {
+		t.Fatalf("ParseHeader() error = %v", err)
+	}
+	if h.Kategorie != 21 || h.Berater != "29098" || h.Mandant != "55003" || h.Waehrung != "EUR" || h.Bezeichnung != "Buchungen September" {
+		t.Errorf("unexpected header: %+v", h)
+	}
+	if h.ErzeugtAm == nil || h.ErzeugtAm.Format("2006-01-02 15:04:05") != "2024-10-05 10:15:30" {
+		t.Errorf("ErzeugtAm = %v", h.ErzeugtAm)
+	}
+	if h.WJBeginn == nil || h.WJBeginn.Month() != time.July {
+		t.Errorf("WJBeginn = %v", h.WJBeginn)
+	}
+
+	for _, line := range []string{"Umsatz;Konto", "EXTF;700;x", "EXTF;700;21;Buchungsstapel;13;gestern"} {
+		if _, err := source.ParseHeader(strings.Split(line, ";")); err == nil {
+			t.Errorf("ParseHeader(%q) expected error", line)
+		}
+	}
+}
+
+func TestBelegdatum(t *testing.T) {
+	vom := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	h := Header{DatumVom: &vom}
+
+	tests := map[string]string{
+		"0107": "2024-07-01",
+		"3112": "2024-12-31",
+		"101":  "2025-01-01",
+		"3006": "2025-06-30",
+	}
+	for value, want := range tests {
+		got, err := belegdatum(value, h, time.UTC)
+		if err != nil {
+			t.Fatalf("belegdatum(%s) error = %v", value, err)
+		}
+		if got.(time.Time).Format("2006-01-02") != want {
+			t.Errorf("belegdatum(%s) = %v, want %s", value, got, want)
+		}
+	}
+	// February 2025 has 28 days, February 2024 of the previous period 29
+	for _, value := range []string{"0113", "3102", "0009", "3204", "3106", "2902"} {
+		if _, err := belegdatum(value, h, time.UTC); err == nil {
+			t.Errorf("belegdatum(%s) expected error", value)
+		}
+	}
+	vom = time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	if got, err := belegdatum("2902", h, time.UTC); err != nil || got.(time.Time).Format("2006-01-02") != "2024-02-29" {
+		t.Errorf("belegdatum(2902) = %v, %v, want 2024-02-29", got, err)
+	}
+	if _, err := belegdatum("0101", Header{}, time.UTC); err == nil {
+		t.Error("expected error without period")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[string]string{
+		"Gegenkonto (ohne BU-Schlüssel)": "gegenkonto",
+		"Zusatzinformation - Art 1":      "zusatzinformation_art_1",
+		"Stück":                          "stueck",
+	}
+	for header, want := range tests {
+		if got := ColumnName(header); got != want {
+			t.Errorf("ColumnName(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
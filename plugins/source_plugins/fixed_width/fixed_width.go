@@ -0,0 +1,372 @@
+package fixed_width
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+// Field is a 1 based character position of a record. Decimals shifts the
+// decimal point of numbers stored without one, e.g. 0001995 with 2 decimals
+// is 19.95.
+type Field struct {
+	Start    int `yaml:"start"`
+	Length   int `yaml:"length"`
+	Decimals int `yaml:"decimals"`
+}
+
+// layoutConfig describes the fields of a record either by position or by
+// the widths of consecutive columns.
+type layoutConfig struct {
+	Fields  map[string]Field `yaml:"fields"`
+	Widths  []int            `yaml:"widths"`
+	Columns []string         `yaml:"columns"`
+	// Carry keeps the values of the record for the following records
+	// instead of emitting it, e.g. for header records.
+	Carry bool `yaml:"carry"`
+}
+
+type fixedWidthConfig struct {
+	fileio.TextOptions        `yaml:",inline"`
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	layoutConfig              `yaml:",inline"`
+	Trim                      *bool                   `yaml:"trim"`
+	RecordType                *Field                  `yaml:"record_type"`
+	RecordTypes               map[string]layoutConfig `yaml:"record_types"`
+	DateFormats               []string                `yaml:"date_formats"`
+	Timezone                  string                  `yaml:"timezone"`
+}
+
+type column struct {
+	Name string
+	Field
+}
+
+// Layout is the resolved field list of a record type, ordered by position.
+type Layout struct {
+	Columns []column
+	Carry   bool
+}
+
+// FixedWidthSource reads files with fixed width records. Files with several
+// record layouts select the layout by the record type field, records of
+// other types are skipped.
+type FixedWidthSource struct {
+	Model       *models.Model
+	Text        fileio.TextOptions
+	Trim        bool
+	Layout      *Layout
+	RecordType  *Field
+	Layouts     map[string]*Layout
+	Convert     convert.Options
+	Compression fileio.CompressionOptions
+	*fileio.Files
+}
+
+func (s *FixedWidthSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := fixedWidthConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid fixed_width config: %v", err)
+	}
+	s.Text = c.TextOptions
+	if _, err := fileio.Encoding(s.Text.Encoding); err != nil {
+		return err
+	}
+	s.Trim = c.Trim == nil || *c.Trim
+
+	var err error
+	if c.RecordType != nil {
+		if err := validateField("record_type", *c.RecordType); err != nil {
+			return err
+		}
+		if len(c.RecordTypes) == 0 {
+			return errors.New("record_types are required with record_type")
+		}
+		s.RecordType = c.RecordType
+		s.Layouts = make(map[string]*Layout, len(c.RecordTypes))
+		for recordType, layout := range c.RecordTypes {
+			if s.Layouts[recordType], err = parseLayout(layout, model); err != nil {
+				return fmt.Errorf("record type %s: %v", recordType, err)
+			}
+		}
+	} else {
+		if len(c.RecordTypes) > 0 {
+			return errors.New("record_type is required with record_types")
+		}
+		if s.Layout, err = parseLayout(c.layoutConfig, model); err != nil {
+			return err
+		}
+	}
+
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{"20060102150405", "20060102", "02.01.2006", "2006-01-02T15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	return s.Compression.Validate()
+}
+
+func validateField(name string, f Field) error {
+	if f.Start < 1 {
+		return fmt.Errorf("%s: start must be at least 1", name)
+	}
+	if f.Length < 1 {
+		return fmt.Errorf("%s: length must be at least 1", name)
+	}
+	if f.Decimals < 0 {
+		return fmt.Errorf("%s: decimals must not be negative", name)
+	}
+	return nil
+}
+
+func hasColumn(model *models.Model, name string) bool {
+	for _, column := range model.Columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLayout resolves fields or widths into the column positions. Widths
+// without columns follow the order of the model columns.
+func parseLayout(c layoutConfig, model *models.Model) (*Layout, error) {
+	layout := &Layout{Carry: c.Carry}
+
+	switch {
+	case len(c.Fields) > 0 && len(c.Widths) > 0:
+		return nil, errors.New("either fields or widths can be configured")
+	case len(c.Fields) > 0:
+		for name, f := range c.Fields {
+			if err := validateField(name, f); err != nil {
+				return nil, err
+			}
+			layout.Columns = append(layout.Columns, column{Name: name, Field: f})
+		}
+	case len(c.Widths) > 0:
+		names := c.Columns
+		if len(names) == 0 {
+			for _, column := range model.Columns {
+				names = append(names, column.Name)
+			}
+		}
+		if len(names) != len(c.Widths) {
+			return nil, fmt.Errorf("%d widths for %d columns", len(c.Widths), len(names))
+		}
+		start := 1
+		for i, width := range c.Widths {
+			f := Field{Start: start, Length: width}
+			if err := validateField(names[i], f); err != nil {
+				return nil, err
+			}
+			start += width
+			// "-" skips filler columns
+			if names[i] != "-" {
+				layout.Columns = append(layout.Columns, column{Name: names[i], Field: f})
+			}
+		}
+	default:
+		return nil, errors.New("fields or widths are required")
+	}
+
+	for _, column := range layout.Columns {
+		if !hasColumn(model, column.Name) {
+			return nil, fmt.Errorf("field %s is not a model column", column.Name)
+		}
+	}
+	sort.Slice(layout.Columns, func(i, j int) bool {
+		return layout.Columns[i].Start < layout.Columns[j].Start
+	})
+	return layout, nil
+}
+
+func (s *FixedWidthSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.ReadStreams(params.FilePath, s.Compression, func(r io.Reader, emit fileio.Emit) error {
+		records, err := s.read(r)
+		for _, record := range records {
+			emit(record)
+		}
+		return err
+	})
+}
+
+// slice returns the characters of the field, fields past the end of the
+// line are not present.
+func slice(line []rune, f Field) (string, bool) {
+	if f.Start > len(line) {
+		return "", false
+	}
+	end := f.Start - 1 + f.Length
+	if end > len(line) {
+		end = len(line)
+	}
+	return string(line[f.Start-1 : end]), true
+}
+
+// read parses the lines of r into records.
+func (s *FixedWidthSource) read(r io.Reader) ([]map[string]interface{}, error) {
+	text, err := fileio.NewTextReader(r, s.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(text)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var records []map[string]interface{}
+	carried := map[string]map[string]interface{}{}
+	lineNumber := s.Text.SkipLines
+	for scanner.Scan() {
+		lineNumber++
+		line := []rune(strings.TrimRight(scanner.Text(), "\r"))
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+
+		layout := s.Layout
+		recordType := ""
+		if s.RecordType != nil {
+			value, _ := slice(line, *s.RecordType)
+			recordType = strings.TrimSpace(value)
+			var ok bool
+			if layout, ok = s.Layouts[recordType]; !ok {
+				log.WithFields(log.Fields{
+					"line":        lineNumber,
+					"record_type": recordType,
+				}).Debug("Skipping record type")
+				continue
+			}
+		}
+
+		record := make(map[string]interface{}, len(layout.Columns))
+		for _, column := range layout.Columns {
+			value, ok := slice(line, column.Field)
+			if !ok {
+				continue
+			}
+			if s.Trim {
+				value = strings.TrimSpace(value)
+			}
+			if column.Decimals > 0 {
+				value = shiftDecimals(value, column.Decimals)
+			}
+			record[column.Name] = value
+		}
+
+		if layout.Carry {
+			carried[recordType] = record
+			continue
+		}
+		for _, carriedType := range sortedKeys(carried) {
+			for name, value := range carried[carriedType] {
+				if _, ok := record[name]; !ok {
+					record[name] = value
+				}
+			}
+		}
+
+		transformedRecord, err := s.Transform(record)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"record": record,
+				"line":   lineNumber,
+				"error":  err,
+			}).Error("Error transforming record")
+			s.Files.Skip()
+			continue
+		}
+		records = append(records, transformedRecord)
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shiftDecimals inserts the decimal point into numbers stored without one,
+// values that are not plain digits are returned unchanged.
+func shiftDecimals(value string, decimals int) string {
+	sign := ""
+	digits := strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(digits, "-"), strings.HasPrefix(digits, "+"):
+		sign, digits = digits[:1], digits[1:]
+	case strings.HasSuffix(digits, "-"), strings.HasSuffix(digits, "+"):
+		// trailing signs are common in mainframe exports
+		sign, digits = digits[len(digits)-1:], digits[:len(digits)-1]
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return value
+	}
+	if sign == "+" {
+		sign = ""
+	}
+	digits = strings.TrimLeft(digits, "0")
+	for len(digits) <= decimals {
+		digits = "0" + digits
+	}
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+// Transform converts the field values into the column types.
+func (s *FixedWidthSource) Transform(record map[string]interface{}) (map[string]interface{}, error) {
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name].(string)
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.String(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *FixedWidthSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("fixed_width", func() plugins.Source {
+		return &FixedWidthSource{}
+	})
+}
@@ -0,0 +1,182 @@
+package fixed_width
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var bookingModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "konto", Type: models.String},
+		{Name: "datum", Type: models.DateTime},
+		{Name: "betrag", Type: models.Float},
+		{Name: "text", Type: models.String},
+	},
+	Unique: []string{"konto", "datum"},
+}
+
+func TestRead(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		input  string
+		want   []map[string]interface{}
+	}{
+		{
+			name: "fields with implied decimals",
+			config: map[string]interface{}{
+				"fields": map[interface{}]interface{}{
+					"konto":  map[interface{}]interface{}{"start": 1, "length": 6},
+					"datum":  map[interface{}]interface{}{"start": 7, "length": 8},
+					"betrag": map[interface{}]interface{}{"start": 15, "length": 8, "decimals": 2},
+					"text":   map[interface{}]interface{}{"start": 23, "length": 20},
+				},
+			},
+			input: "12345 2024090100199500Miete September\r\n" +
+				"\r\n" +
+				"12345 202409020000050-Gebühr\n" +
+				"54321 2024090300000001",
+			want: []map[string]interface{}{
+				{"konto": "12345", "datum": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin), "betrag": 1995.0, "text": "Miete September"},
+				{"konto": "12345", "datum": time.Date(2024, 9, 2, 0, 0, 0, 0, berlin), "betrag": -0.5, "text": "Gebühr"},
+				{"konto": "54321", "datum": time.Date(2024, 9, 3, 0, 0, 0, 0, berlin), "betrag": 0.01, "text": nil},
+			},
+		},
+		{
+			name: "widths with filler and columns",
+			config: map[string]interface{}{
+				"widths":     []interface{}{6, 2, 10, 9},
+				"columns":    []interface{}{"konto", "-", "datum", "betrag"},
+				"skip_lines": 1,
+			},
+			input: "KONTO   DATUM     BETRAG\n" +
+				"12345 XX01.09.2024   19,95\n",
+			want: []map[string]interface{}{
+				{"konto": "12345", "datum": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin), "betrag": 19.95, "text": nil},
+			},
+		},
+		{
+			name: "record types with carried header",
+			config: map[string]interface{}{
+				"record_type": map[interface{}]interface{}{"start": 1, "length": 2},
+				"record_types": map[interface{}]interface{}{
+					"01": map[interface{}]interface{}{
+						"carry":   true,
+						"widths":  []interface{}{2, 6},
+						"columns": []interface{}{"-", "konto"},
+					},
+					"02": map[interface{}]interface{}{
+						"widths":  []interface{}{2, 8, 6, 10},
+						"columns": []interface{}{"-", "datum", "betrag", "text"},
+					},
+				},
+			},
+			input: "0111111 \n" +
+				"0220240901  1,00Eins\n" +
+				"99Summe\n" +
+				"0122222 \n" +
+				"0220240902  2,00Zwei\n",
+			want: []map[string]interface{}{
+				{"konto": "11111", "betrag": 1.0, "text": "Eins"},
+				{"konto": "22222", "betrag": 2.0, "text": "Zwei"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &FixedWidthSource{}
+			if err := source.Init(tt.config, bookingModel); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			records, err := source.read(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d: %v", len(tt.want), len(records), records)
+			}
+			for i := range tt.want {
+				for column, value := range tt.want[i] {
+					got := records[i][column]
+					if ts, ok := value.(time.Time); ok {
+						if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+							t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+						}
+						continue
+					}
+					if !reflect.DeepEqual(got, value) {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadEncoding(t *testing.T) {
+	input, err := charmap.CodePage850.NewEncoder().String("12345 20240901000100Überweisung")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &FixedWidthSource{}
+	err = source.Init(map[string]interface{}{
+		"encoding": "cp850",
+		"widths":   []interface{}{6, 8, 6, 20},
+		"fields":   nil,
+	}, bookingModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := source.read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0]["text"] != "Überweisung" {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no layout":       {},
+		"widths mismatch": {"widths": []interface{}{1, 2}},
+		"unknown column":  {"widths": []interface{}{1}, "columns": []interface{}{"fehlt"}},
+		"invalid start": {"fields": map[interface{}]interface{}{
+			"konto": map[interface{}]interface{}{"start": 0, "length": 2},
+		}},
+		"record types without discriminator": {"record_types": map[interface{}]interface{}{
+			"01": map[interface{}]interface{}{"widths": []interface{}{1}, "columns": []interface{}{"konto"}},
+		}},
+		"unknown encoding": {"encoding": "ebcdic-xyz", "widths": []interface{}{1}, "columns": []interface{}{"konto"}},
+	}
+	for name, cfg := range tests {
+		if err := (&FixedWidthSource{}).Init(cfg, bookingModel); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestShiftDecimals(t *testing.T) {
+	tests := map[string]string{
+		"0001995": "19.95",
+		"5":       "0.05",
+		"-120":    "-1.20",
+		"120-":    "-1.20",
+		"+120":    "1.20",
+		"12,5":    "12,5",
+		"":        "",
+	}
+	for value, want := range tests {
+		if got := shiftDecimals(value, 2); got != want {
+			t.Errorf("shiftDecimals(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
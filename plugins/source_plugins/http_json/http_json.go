@@ -0,0 +1,443 @@
+package http_json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/jsonpath"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PaginationNone   = ""
+	PaginationPage   = "page"
+	PaginationOffset = "offset"
+	PaginationLink   = "link"
+	PaginationCursor = "cursor"
+)
+
+// Pagination configures how the pages of an API are requested.
+//
+//   - page: sends `param` (default page) starting at `start` (default 1)
+//   - offset: sends `param` (default offset) increased by the records received
+//   - link: follows the rel="next" URL of the Link header
+//   - cursor: sends the value found at `cursor_field` as `param` (default cursor)
+//
+// `size_param` and `size` add a page size to every request, `max_pages`
+// stops after the given number of pages.
+type Pagination struct {
+	Type        string `yaml:"type"`
+	Param       string `yaml:"param"`
+	Start       *int   `yaml:"start"`
+	SizeParam   string `yaml:"size_param"`
+	Size        int    `yaml:"size"`
+	CursorField string `yaml:"cursor_field"`
+	MaxPages    int    `yaml:"max_pages"`
+}
+
+type sourceConfig struct {
+	Method      string            `yaml:"method"`
+	URL         string            `yaml:"url"`
+	Headers     map[string]string `yaml:"headers"`
+	Body        string            `yaml:"body"`
+	Records     string            `yaml:"records"`
+	Fields      map[string]string `yaml:"fields"`
+	DateFormats []string          `yaml:"date_formats"`
+	Timezone    string            `yaml:"timezone"`
+	Pagination  Pagination        `yaml:"pagination"`
+	RateLimit   struct {
+		RequestsPerSecond float64 `yaml:"requests_per_second"`
+	} `yaml:"rate_limit"`
+}
+
+type HTTPJSONSource struct {
+	Model      *models.Model
+	Method     string
+	URL        *tmpl.Template
+	Body       *tmpl.Template
+	Headers    map[string]string
+	Records    jsonpath.Path
+	Fields     map[string]jsonpath.Path
+	Pagination Pagination
+	Cursor     jsonpath.Path
+	// Interval is the minimum time between two requests.
+	Interval time.Duration
+	Auth     *httpauth.Auth
+	Retry    *retry.Policy
+	Convert  convert.Options
+
+	lastRequest time.Time
+}
+
+func (s *HTTPJSONSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	sc := sourceConfig{}
+	if err := config.Decode(cfg, &sc); err != nil {
+		return fmt.Errorf("invalid http_json config: %v", err)
+	}
+	if sc.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	var err error
+	s.Method = strings.ToUpper(sc.Method)
+	if s.Method == "" {
+		s.Method = "GET"
+	}
+	if s.URL, err = tmpl.Parse("url", sc.URL); err != nil {
+		return err
+	}
+	if sc.Body != "" {
+		if s.Body, err = tmpl.Parse("body", sc.Body); err != nil {
+			return err
+		}
+	}
+	s.Headers = sc.Headers
+
+	if s.Records, err = jsonpath.Parse(sc.Records); err != nil {
+		return err
+	}
+	s.Fields = make(map[string]jsonpath.Path, len(model.Columns))
+	for _, column := range model.Columns {
+		selector, ok := sc.Fields[column.Name]
+		if !ok {
+			selector = column.Name
+		}
+		if s.Fields[column.Name], err = jsonpath.Parse(selector); err != nil {
+			return err
+		}
+	}
+	for name := range sc.Fields {
+		if _, ok := s.Fields[name]; !ok {
+			return fmt.Errorf("field %s is not a model column", name)
+		}
+	}
+
+	if s.Pagination, err = parsePagination(sc.Pagination); err != nil {
+		return err
+	}
+	if s.Pagination.Type == PaginationCursor {
+		if s.Cursor, err = jsonpath.Parse(s.Pagination.CursorField); err != nil {
+			return err
+		}
+	}
+
+	if sc.RateLimit.RequestsPerSecond > 0 {
+		s.Interval = time.Duration(float64(time.Second) / sc.RateLimit.RequestsPerSecond)
+	}
+
+	s.Convert.DateLayouts = sc.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(sc.Timezone); err != nil {
+		return err
+	}
+
+	authConfig, ok := cfg["auth"]
+	if !ok {
+		authConfig = map[string]interface{}{"type": httpauth.None}
+	}
+	if s.Auth, err = httpauth.Parse(authConfig); err != nil {
+		return err
+	}
+
+	if s.Retry, err = retry.ParsePolicy(cfg); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parsePagination(p Pagination) (Pagination, error) {
+	start := func(value int) *int { return &value }
+
+	switch p.Type {
+	case PaginationNone, PaginationLink:
+	case PaginationPage:
+		if p.Param == "" {
+			p.Param = "page"
+		}
+		if p.Start == nil {
+			p.Start = start(1)
+		}
+	case PaginationOffset:
+		if p.Param == "" {
+			p.Param = "offset"
+		}
+		if p.Start == nil {
+			p.Start = start(0)
+		}
+	case PaginationCursor:
+		if p.Param == "" {
+			p.Param = "cursor"
+		}
+		if p.CursorField == "" {
+			return Pagination{}, fmt.Errorf("pagination cursor_field is required for cursor pagination")
+		}
+	default:
+		return Pagination{}, fmt.Errorf("invalid pagination type: %s", p.Type)
+	}
+	return p, nil
+}
+
+func (s *HTTPJSONSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	data := params.TemplateData()
+
+	baseURL, err := s.URL.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	var body string
+	if s.Body != nil {
+		if body, err = s.Body.Render(data); err != nil {
+			return nil, err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"url":      baseURL,
+		"start_at": params.StartAt,
+		"end_at":   params.EndAt,
+	}).Info("HTTPJSONSource:FetchData")
+
+	totalErrored := 0
+	records := make([]map[string]interface{}, 0)
+
+	requestURL := baseURL
+	position := 0
+	if s.Pagination.Start != nil {
+		position = *s.Pagination.Start
+	}
+	for pageNumber := 1; ; pageNumber++ {
+		switch s.Pagination.Type {
+		case PaginationPage, PaginationOffset:
+			requestURL, err = withQuery(baseURL, s.Pagination.Param, strconv.Itoa(position))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if s.Pagination.SizeParam != "" && s.Pagination.Size > 0 {
+			requestURL, err = withQuery(requestURL, s.Pagination.SizeParam, strconv.Itoa(s.Pagination.Size))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		document, header, err := s.fetch(requestURL, body)
+		if err != nil {
+			return nil, err
+		}
+
+		items := s.Records.Select(document)
+		log.WithFields(log.Fields{
+			"page": pageNumber,
+			"url":  requestURL,
+			"size": len(items),
+		}).Debug("HTTPJSONSource:fetched page")
+
+		for _, item := range items {
+			record, err := s.Transform(item)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"item": item,
+					"err":  err,
+				}).Errorf("Error transforming record: %v", err)
+				totalErrored++
+				continue
+			}
+			records = append(records, record)
+		}
+
+		if len(items) == 0 || (s.Pagination.MaxPages > 0 && pageNumber >= s.Pagination.MaxPages) {
+			break
+		}
+
+		next := ""
+		switch s.Pagination.Type {
+		case PaginationPage:
+			position++
+			continue
+		case PaginationOffset:
+			position += len(items)
+			continue
+		case PaginationLink:
+			next, err = nextLink(requestURL, header.Get("Link"))
+			if err != nil {
+				return nil, err
+			}
+		case PaginationCursor:
+			if cursor, ok := s.Cursor.Get(document); ok && cursor != nil && fmt.Sprint(cursor) != "" {
+				next, err = withQuery(baseURL, s.Pagination.Param, fmt.Sprint(cursor))
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if next == "" {
+			break
+		}
+		requestURL = next
+	}
+
+	log.WithFields(log.Fields{
+		"total_records": len(records),
+		"total_errored": totalErrored,
+	}).Info("HTTPJSONSource:FetchData finished")
+
+	return records, nil
+}
+
+// fetch requests a page, respecting the rate limit and retrying transient
+// failures, and returns the decoded document.
+func (s *HTTPJSONSource) fetch(requestURL string, body string) (interface{}, http.Header, error) {
+	var document interface{}
+	var header http.Header
+	err := s.Retry.Do("http_json", func() error {
+		s.wait()
+
+		var reqBody io.Reader
+		if body != "" {
+			reqBody = bytes.NewBufferString(body)
+		}
+		req, err := http.NewRequest(s.Method, requestURL, reqBody)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for key, value := range s.Headers {
+			req.Header.Set(key, value)
+		}
+		if err := s.Auth.Apply(req); err != nil {
+			return err
+		}
+
+		resp, err := s.Auth.Client().Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to fetch data: %w", &retry.StatusError{
+				StatusCode: resp.StatusCode,
+				Body:       string(bodyBytes),
+			})
+		}
+
+		decoder := json.NewDecoder(resp.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return err
+		}
+		header = resp.Header
+		return nil
+	})
+	return document, header, err
+}
+
+// wait blocks until the rate limit allows the next request.
+func (s *HTTPJSONSource) wait() {
+	if s.Interval > 0 && !s.lastRequest.IsZero() {
+		if wait := s.Interval - time.Since(s.lastRequest); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	s.lastRequest = time.Now()
+}
+
+func withQuery(rawURL, key, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// nextLink returns the rel="next" target of a Link header resolved against
+// the current URL, or the empty string.
+func nextLink(current, header string) (string, error) {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key != "rel" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				if rel != "next" {
+					continue
+				}
+				base, err := url.Parse(current)
+				if err != nil {
+					return "", err
+				}
+				next, err := base.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return "", err
+				}
+				return next.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Transform maps a record of the response onto the model columns.
+func (s *HTTPJSONSource) Transform(item interface{}) (map[string]interface{}, error) {
+	record := make(map[string]interface{}, len(s.Model.Columns))
+	for _, column := range s.Model.Columns {
+		value, ok := s.Fields[column.Name].Get(item)
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *HTTPJSONSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("http_json", func() plugins.Source {
+		return &HTTPJSONSource{}
+	})
+}
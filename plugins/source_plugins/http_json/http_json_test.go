@@ -0,0 +1,244 @@
+package http_json
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var ordersModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "order_id", Type: models.BigInt},
+		{Name: "total", Type: models.Float},
+		{Name: "time", Type: models.DateTime},
+		{Name: "status", Type: models.String},
+	},
+	Unique: []string{"order_id", "time"},
+}
+
+// orders returns the orders of a page, three pages with two orders each.
+func orders(page int) []interface{} {
+	if page < 1 || page > 3 {
+		return []interface{}{}
+	}
+	result := []interface{}{}
+	for i := 0; i < 2; i++ {
+		id := (page-1)*2 + i + 1
+		result = append(result, map[string]interface{}{
+			"id":         id,
+			"created_at": fmt.Sprintf("2024-09-25T10:%02d:00Z", id),
+			"totals":     map[string]interface{}{"gross": fmt.Sprintf("%d.50", id)},
+			"status":     "paid",
+		})
+	}
+	return result
+}
+
+func newSource(t *testing.T, cfg map[string]interface{}) *HTTPJSONSource {
+	cfg["records"] = "$.data.orders[*]"
+	cfg["fields"] = map[interface{}]interface{}{
+		"order_id": "id",
+		"total":    "totals.gross",
+		"time":     "created_at",
+	}
+	source := &HTTPJSONSource{}
+	if err := source.Init(cfg, ordersModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return source
+}
+
+func fetch(t *testing.T, source *HTTPJSONSource) []map[string]interface{} {
+	records, err := source.FetchData(map[string]interface{}{
+		"start_at": time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC),
+		"end_at":   time.Date(2024, 9, 26, 0, 0, 0, 0, time.UTC),
+		"vars":     map[string]interface{}{"shop": "de"},
+	})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	return records
+}
+
+func TestFetchDataPagePagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("since") != "2024-09-25" || r.URL.Query().Get("shop") != "de" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("per_page") != "2" {
+			t.Errorf("expected per_page=2, got %s", r.URL.RawQuery)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"orders": orders(page)},
+		})
+	}))
+	defer server.Close()
+
+	source := newSource(t, map[string]interface{}{
+		"url": server.URL + `/orders?since={{ date "date" .start_at }}&shop={{ .shop }}`,
+		"pagination": map[interface{}]interface{}{
+			"type":       "page",
+			"size_param": "per_page",
+			"size":       2,
+		},
+	})
+	records := fetch(t, source)
+
+	if len(records) != 6 {
+		t.Fatalf("expected 6 records, got %d", len(records))
+	}
+	first := records[0]
+	if first["order_id"] != int64(1) || first["total"] != 1.5 || first["status"] != "paid" {
+		t.Errorf("unexpected record %v", first)
+	}
+	if !first["time"].(time.Time).Equal(time.Date(2024, 9, 25, 10, 1, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %v", first["time"])
+	}
+}
+
+func TestFetchDataOffsetPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"orders": orders(offset/2 + 1)},
+		})
+	}))
+	defer server.Close()
+
+	source := newSource(t, map[string]interface{}{
+		"url":        server.URL,
+		"pagination": map[interface{}]interface{}{"type": "offset"},
+	})
+	if records := fetch(t, source); len(records) != 6 {
+		t.Errorf("expected 6 records, got %d", len(records))
+	}
+}
+
+func TestFetchDataLinkPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</orders?p=%d>; rel="next", </orders?p=1>; rel="first"`, page+1))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"orders": orders(page)},
+		})
+	}))
+	defer server.Close()
+
+	source := newSource(t, map[string]interface{}{
+		"url":        server.URL + "/orders",
+		"pagination": map[interface{}]interface{}{"type": "link"},
+	})
+	if records := fetch(t, source); len(records) != 6 {
+		t.Errorf("expected 6 records, got %d", len(records))
+	}
+}
+
+func TestFetchDataCursorPagination(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page := 1
+		if cursor := r.URL.Query().Get("after"); cursor != "" {
+			page, _ = strconv.Atoi(cursor)
+		}
+		next := interface{}(nil)
+		if page < 2 {
+			next = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"orders": orders(page)},
+			"meta": map[string]interface{}{"next": next},
+		})
+	}))
+	defer server.Close()
+
+	source := newSource(t, map[string]interface{}{
+		"url": server.URL,
+		"pagination": map[interface{}]interface{}{
+			"type":         "cursor",
+			"param":        "after",
+			"cursor_field": "meta.next",
+		},
+	})
+	if records := fetch(t, source); len(records) != 4 {
+		t.Errorf("expected 4 records, got %d", len(records))
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestFetchDataRateLimitAndAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"orders": orders(page)},
+		})
+	}))
+	defer server.Close()
+
+	source := newSource(t, map[string]interface{}{
+		"url":        server.URL,
+		"pagination": map[interface{}]interface{}{"type": "page"},
+		"rate_limit": map[interface{}]interface{}{"requests_per_second": 20},
+		"auth": map[interface{}]interface{}{
+			"type":  "bearer",
+			"token": map[interface{}]interface{}{"value": "secret"},
+		},
+	})
+
+	start := time.Now()
+	if records := fetch(t, source); len(records) != 6 {
+		t.Errorf("expected 6 records, got %d", len(records))
+	}
+	// four requests need at least three intervals of 50ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected rate limited requests, took %v", elapsed)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  map[string]interface{}
+	}{
+		{"missing url", map[string]interface{}{}},
+		{"unknown field column", map[string]interface{}{
+			"url":    "http://localhost",
+			"fields": map[interface{}]interface{}{"unknown": "id"},
+		}},
+		{"cursor without field", map[string]interface{}{
+			"url":        "http://localhost",
+			"pagination": map[interface{}]interface{}{"type": "cursor"},
+		}},
+		{"invalid selector", map[string]interface{}{
+			"url":     "http://localhost",
+			"records": "data[",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &HTTPJSONSource{}
+			if err := source.Init(tt.cfg, ordersModel); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
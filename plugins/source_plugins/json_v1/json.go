@@ -0,0 +1,252 @@
+package json
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/jsonpath"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatAuto   = "auto"
+	FormatNDJSON = "ndjson"
+	FormatArray  = "array"
+)
+
+type jsonConfig struct {
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	Format                    string   `yaml:"format"`
+	Records                   string   `yaml:"records"`
+	Flatten                   bool     `yaml:"flatten"`
+	FlattenSeparator          string   `yaml:"flatten_separator"`
+	DateFormats               []string `yaml:"date_formats"`
+	Timezone                  string   `yaml:"timezone"`
+}
+
+// JSONSource reads JSON Lines files and files holding a top level array.
+// Every top level value (a line or an array element) is a document, the
+// records selector picks the records of a document.
+type JSONSource struct {
+	Model   *models.Model
+	Format  string
+	Records jsonpath.Path
+	// Flatten turns nested objects into keys joined by FlattenSeparator,
+	// e.g. {"customer": {"id": 1}} becomes {"customer.id": 1}.
+	Flatten          bool
+	FlattenSeparator string
+	Mapping          *mapping.Mapping
+	Convert          convert.Options
+	Compression      fileio.CompressionOptions
+	*fileio.Files
+}
+
+func (s *JSONSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := jsonConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid json config: %v", err)
+	}
+
+	switch c.Format {
+	case "":
+		s.Format = FormatAuto
+	case FormatAuto, FormatNDJSON, FormatArray:
+		s.Format = c.Format
+	default:
+		return fmt.Errorf("invalid format: %s", c.Format)
+	}
+
+	var err error
+	if s.Records, err = jsonpath.Parse(c.Records); err != nil {
+		return err
+	}
+	s.Flatten = c.Flatten
+	s.FlattenSeparator = c.FlattenSeparator
+	if s.FlattenSeparator == "" {
+		s.FlattenSeparator = "."
+	}
+
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	return s.Compression.Validate()
+}
+
+func (s *JSONSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.ReadStreams(params.FilePath, s.Compression, s.read)
+}
+
+// read decodes the documents of r one at a time and passes the transformed
+// records to fn. Records that can not be transformed are logged and skipped.
+func (s *JSONSource) read(r io.Reader, fn func(record map[string]interface{})) error {
+	text, err := fileio.NewTextReader(r, fileio.TextOptions{})
+	if err != nil {
+		return err
+	}
+
+	format := s.Format
+	if format == FormatAuto {
+		if format, err = detectFormat(text); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(text)
+	decoder.UseNumber()
+
+	document := 0
+	handle := func(value interface{}) {
+		document++
+		for _, item := range s.Records.Select(value) {
+			record, err := s.Transform(item)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"document": document,
+					"error":    err,
+				}).Error("Error transforming record")
+				s.Files.Skip()
+				continue
+			}
+			fn(record)
+		}
+	}
+
+	if format == FormatArray {
+		if err := jsonpath.ExpectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return fmt.Errorf("element %d: %v", document+1, err)
+			}
+			handle(value)
+		}
+		return jsonpath.ExpectDelim(decoder, ']')
+	}
+
+	for {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("document %d: %v", document+1, err)
+		}
+		handle(value)
+	}
+}
+
+// detectFormat peeks at the first non white space character, a file starting
+// with `[` holds an array, everything else is read as JSON Lines.
+func detectFormat(r *bufio.Reader) (string, error) {
+	for i := 1; ; i++ {
+		head, err := r.Peek(i)
+		if len(head) < i {
+			if err == io.EOF || err == bufio.ErrBufferFull {
+				return FormatNDJSON, nil
+			}
+			return "", err
+		}
+		switch head[i-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return FormatArray, nil
+		default:
+			return FormatNDJSON, nil
+		}
+	}
+}
+
+// Transform maps a record onto the model columns and converts the values.
+func (s *JSONSource) Transform(item interface{}) (map[string]interface{}, error) {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("record is not an object")
+	}
+	if s.Flatten {
+		object = flatten(object, s.FlattenSeparator)
+	}
+
+	record, err := s.Mapping.Record(s.Model, object)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+// flatten joins the keys of nested objects, arrays are kept as values.
+func flatten(object map[string]interface{}, separator string) map[string]interface{} {
+	result := make(map[string]interface{}, len(object))
+	var walk func(prefix string, value map[string]interface{})
+	walk = func(prefix string, value map[string]interface{}) {
+		for key, child := range value {
+			if prefix != "" {
+				key = prefix + separator + key
+			}
+			if nested, ok := child.(map[string]interface{}); ok && len(nested) > 0 {
+				walk(key, nested)
+				continue
+			}
+			result[key] = child
+		}
+	}
+	walk("", object)
+	return result
+}
+
+func (s *JSONSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("json", func() plugins.Source {
+		return &JSONSource{}
+	})
+}
@@ -0,0 +1,164 @@
+package json
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var orderModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "id", Type: models.String},
+		{Name: "customer_id", Type: models.Int},
+		{Name: "customer_name", Type: models.String},
+		{Name: "total", Type: models.Float},
+		{Name: "paid", Type: models.Bool},
+		{Name: "created_at", Type: models.DateTime},
+		{Name: "items", Type: models.JSON},
+	},
+	Unique: []string{"id"},
+}
+
+func TestFetchData(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	want := []map[string]interface{}{
+		{
+			"id": "A-1", "customer_id": 7, "customer_name": "Müller", "total": 19.99, "paid": true,
+			"created_at": time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC), "items": `[{"sku":"x"}]`,
+		},
+		{
+			"id": "A-2", "customer_id": 8, "customer_name": "Schmidt", "total": 5.5, "paid": false,
+			"created_at": time.Date(2024, 9, 2, 8, 30, 0, 0, berlin), "items": "[]",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		config map[string]interface{}
+	}{
+		{
+			name: "ndjson flattened",
+			file: "testdata/orders.ndjson",
+			config: map[string]interface{}{
+				"flatten":           true,
+				"flatten_separator": "_",
+			},
+		},
+		{
+			name: "array with record path and mapping",
+			file: "testdata/orders.json",
+			config: map[string]interface{}{
+				"records": "$.data.orders[*]",
+				"flatten": true,
+				"mapping": map[interface{}]interface{}{
+					"columns": map[interface{}]interface{}{
+						"customer_id":   "customer.id",
+						"customer_name": "customer.name",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &JSONSource{}
+			if err := source.Init(tt.config, orderModel); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			// the fixtures hold a broken record, FetchData fails the file
+			file, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var records []map[string]interface{}
+			if err := source.read(file, func(record map[string]interface{}) { records = append(records, record) }); err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if len(records) != len(want) {
+				t.Fatalf("expected %d records, got %d: %v", len(want), len(records), records)
+			}
+			for i := range want {
+				for column, value := range want[i] {
+					got := records[i][column]
+					if ts, ok := value.(time.Time); ok {
+						if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+							t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+						}
+						continue
+					}
+					if !reflect.DeepEqual(got, value) {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	model := &models.Model{Columns: []models.Column{{Name: "id", Type: models.Int}}}
+
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{name: "ndjson", input: "{\"id\": 1}\n{\"id\": 2}\n", want: 2},
+		{name: "array with bom and white space", input: "\ufeff \n [{\"id\": 1}, {\"id\": 2}, {\"id\": 3}]", want: 3},
+		{name: "single document", input: "{\"id\": 1}", want: 1},
+		{name: "empty", input: "", want: 0},
+		{name: "non objects are skipped", input: "[1, {\"id\": 2}]", want: 1},
+		{name: "ndjson line holding an array", format: "ndjson", input: "[{\"id\": 1}, {\"id\": 2}]\n{\"id\": 3}", want: 3},
+		{name: "broken", input: "{\"id\": 1}\n{\"id\":", wantErr: true},
+		{name: "forced array", format: "array", input: "{\"id\": 1}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &JSONSource{}
+			if err := source.Init(map[string]interface{}{"format": tt.format}, model); err != nil {
+				t.Fatal(err)
+			}
+			count := 0
+			err := source.read(strings.NewReader(tt.input), func(map[string]interface{}) { count++ })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && count != tt.want {
+				t.Errorf("read() records = %d, want %d", count, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	got := flatten(map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": map[string]interface{}{"d": "x"}},
+		"e": []interface{}{1},
+		"f": map[string]interface{}{},
+	}, ".")
+	want := map[string]interface{}{"a.b": 1, "a.c.d": "x", "e": []interface{}{1}, "f": map[string]interface{}{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flatten() = %v, want %v", got, want)
+	}
+}
+
+func TestFetchDataMissingFile(t *testing.T) {
+	source := &JSONSource{}
+	if err := source.Init(map[string]interface{}{}, orderModel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.FetchData(map[string]interface{}{"file_path": filepath.Join(t.TempDir(), "missing.json")}); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
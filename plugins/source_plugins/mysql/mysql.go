@@ -0,0 +1,267 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	gomysql "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/text/encoding"
+)
+
+type mysqlConfig struct {
+	DSNEnv      string   `yaml:"dsn_env"`
+	Query       string   `yaml:"query"`
+	Args        []string `yaml:"args"`
+	Charset     string   `yaml:"charset"`
+	Encoding    string   `yaml:"encoding"`
+	DateFormats []string `yaml:"date_formats"`
+	Timezone    string   `yaml:"timezone"`
+}
+
+// MySQLSource runs a query against MySQL or MariaDB. The query is a template
+// like the sql_api queries, Args bind template variables to the `?`
+// placeholders so the window does not have to be quoted.
+type MySQLSource struct {
+	Model    *models.Model
+	DB       *sql.DB
+	Template *tmpl.Template
+	Args     []string
+	// Encoding decodes text of legacy tables that store another charset
+	// than they declare, nil keeps the text as returned by the server.
+	Encoding encoding.Encoding
+	Retry    *retry.Policy
+	Mapping  *mapping.Mapping
+	Convert  convert.Options
+}
+
+func (s *MySQLSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := mysqlConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid mysql config: %v", err)
+	}
+	if c.Query == "" {
+		return fmt.Errorf("query is required")
+	}
+	if c.DSNEnv == "" {
+		c.DSNEnv = "MYSQL_DSN"
+	}
+	dsn := os.Getenv(c.DSNEnv)
+	if dsn == "" {
+		return fmt.Errorf("%s environment variable is required", c.DSNEnv)
+	}
+
+	var err error
+	if s.Template, err = tmpl.Parse("query", c.Query); err != nil {
+		return err
+	}
+	s.Args = c.Args
+
+	if c.Encoding != "" {
+		if s.Encoding, err = fileio.Encoding(c.Encoding); err != nil {
+			return err
+		}
+	}
+
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	if s.Retry, err = retry.ParsePolicy(cfg); err != nil {
+		return err
+	}
+
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{"2006-01-02 15:04:05.999999", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	dbConfig, err := Config(dsn, c.Charset, s.Convert.Location)
+	if err != nil {
+		return err
+	}
+	connector, err := gomysql.NewConnector(dbConfig)
+	if err != nil {
+		return err
+	}
+	s.DB = sql.OpenDB(connector)
+	return nil
+}
+
+// Config parses the DSN and applies the connection settings. Without a
+// charset in the config or the DSN the connection uses utf8mb4, so the
+// server converts the text of latin1 tables. Datetimes are read as text to
+// keep zero dates, and time arguments are sent in loc.
+func Config(dsn, charset string, loc *time.Location) (*gomysql.Config, error) {
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mysql dsn: %v", err)
+	}
+	if charset == "" && !strings.Contains(dsn, "charset=") {
+		charset = "utf8mb4"
+	}
+	if charset != "" {
+		if err := cfg.Apply(gomysql.Charset(charset, "")); err != nil {
+			return nil, err
+		}
+	}
+	cfg.ParseTime = false
+	cfg.Loc = loc
+	return cfg, nil
+}
+
+func (s *MySQLSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	data := params.TemplateData()
+	query, err := s.Template.Render(data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering query: %v", err)
+	}
+	args := make([]interface{}, len(s.Args))
+	for i, name := range s.Args {
+		value, ok := data[name]
+		if !ok {
+			return nil, fmt.Errorf("query argument %s is not a variable", name)
+		}
+		args[i] = value
+	}
+	log.WithFields(log.Fields{
+		"start_at": params.StartAt,
+		"end_at":   params.EndAt,
+		"query":    query,
+		"args":     args,
+	}).Info("MySQLSource:FetchData")
+
+	// only the query is retried, the rows are streamed afterwards
+	var rows *sql.Rows
+	err = s.Retry.Do("mysql", func() error {
+		var err error
+		rows, err = s.DB.Query(query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	index := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		index++
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+
+		record, err := s.Transform(row)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"row":   index,
+				"error": err,
+			}).Error("Error transforming record")
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Transform maps a row onto the model columns and converts the values.
+// Text, decimals and datetimes arrive as bytes and are parsed like strings,
+// zero dates become nil.
+func (s *MySQLSource) Transform(row map[string]interface{}) (map[string]interface{}, error) {
+	record, err := s.Mapping.Record(s.Model, row)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+
+		if b, ok := value.([]byte); ok {
+			str := string(b)
+			if s.Encoding != nil && (column.Type == models.String || column.Type == models.JSON) {
+				if str, err = s.Encoding.NewDecoder().String(str); err != nil {
+					return nil, fmt.Errorf("error decoding column %s: %v", column.Name, err)
+				}
+			}
+			value = str
+		}
+		if str, ok := value.(string); ok && (column.Type == models.DateTime || column.Type == models.DateTimeNullable) && ZeroDate(str) {
+			if column.Type == models.DateTime {
+				return nil, fmt.Errorf("column %s is a zero date", column.Name)
+			}
+			value = nil
+		}
+
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+// ZeroDate reports whether the value is a MySQL zero date like 0000-00-00
+// or 0000-00-00 00:00:00, or a date with a zero month or day.
+func ZeroDate(value string) bool {
+	if len(value) < 10 {
+		return false
+	}
+	return value[:4] == "0000" || value[5:7] == "00" || value[8:10] == "00"
+}
+
+func (s *MySQLSource) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
+
+func init() {
+	plugins.RegisterSource("mysql", func() plugins.Source {
+		return &MySQLSource{}
+	})
+}
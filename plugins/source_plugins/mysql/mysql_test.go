@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var orderModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "created", Type: models.DateTime},
+		{Name: "shipped", Type: models.DateTimeNullable},
+		{Name: "order_id", Type: models.BigInt},
+		{Name: "customer", Type: models.String},
+		{Name: "total", Type: models.Float},
+		{Name: "paid", Type: models.Bool},
+	},
+	Unique: []string{"order_id"},
+}
+
+func TestZeroDate(t *testing.T) {
+	tests := map[string]bool{
+		"0000-00-00":          true,
+		"0000-00-00 00:00:00": true,
+		"2024-00-00":          true,
+		"2024-09-00 00:00:00": true,
+		"2024-09-01":          false,
+		"2024-09-01 08:00:00": false,
+		"":                    false,
+	}
+	for value, want := range tests {
+		if got := ZeroDate(value); got != want {
+			t.Errorf("ZeroDate(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name    string
+		dsn     string
+		charset string
+		want    string
+	}{
+		{"default charset", "shop:secret@tcp(db:3306)/shop", "", "charset=utf8mb4"},
+		{"dsn charset", "shop:secret@tcp(db:3306)/shop?charset=latin1", "", "charset=latin1"},
+		{"config charset", "shop:secret@tcp(db:3306)/shop?charset=latin1", "utf8", "charset=utf8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Config(tt.dsn, tt.charset, berlin)
+			if err != nil {
+				t.Fatalf("Config() error = %v", err)
+			}
+			dsn := cfg.FormatDSN()
+			if !strings.Contains(dsn, tt.want) {
+				t.Errorf("FormatDSN() = %s, want %s", dsn, tt.want)
+			}
+			if cfg.ParseTime || cfg.Loc != berlin {
+				t.Errorf("ParseTime = %v, Loc = %v", cfg.ParseTime, cfg.Loc)
+			}
+		})
+	}
+
+	if _, err := Config("not a dsn", "", berlin); err == nil {
+		t.Error("expected error for invalid dsn")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	t.Setenv("MYSQL_DSN", "shop:secret@tcp(db:3306)/shop")
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	source := &MySQLSource{}
+	err := source.Init(map[string]interface{}{
+		"query":    "SELECT * FROM orders WHERE created >= ? AND created < ?",
+		"args":     []interface{}{"start_at", "end_at"},
+		"encoding": "windows-1252",
+		"mapping": map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"order_id": "id"},
+		},
+	}, orderModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer source.Close()
+
+	tests := []struct {
+		name    string
+		row     map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "text protocol",
+			row: map[string]interface{}{
+				"created": []byte("2024-09-01 08:30:00"), "shipped": []byte("0000-00-00 00:00:00"),
+				"id": []byte("4711"), "customer": []byte("M\xfcller"), "total": []byte("119.90"), "paid": []byte("1"),
+			},
+			want: map[string]interface{}{
+				"created": time.Date(2024, 9, 1, 8, 30, 0, 0, berlin), "shipped": nil,
+				"order_id": int64(4711), "customer": "Müller", "total": 119.9, "paid": true,
+			},
+		},
+		{
+			name: "binary protocol",
+			row: map[string]interface{}{
+				"created": []byte("2024-09-01 08:30:00.250000"), "shipped": nil,
+				"id": int64(4712), "customer": nil, "total": float64(5), "paid": int64(0),
+			},
+			want: map[string]interface{}{
+				"created": time.Date(2024, 9, 1, 8, 30, 0, 250000000, berlin), "shipped": nil,
+				"order_id": int64(4712), "customer": nil, "total": 5.0, "paid": false,
+			},
+		},
+		{
+			name:    "zero date in required column",
+			row:     map[string]interface{}{"created": []byte("0000-00-00 00:00:00"), "id": int64(1)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.Transform(tt.row)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Transform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitRequiresDSN(t *testing.T) {
+	t.Setenv("MYSQL_DSN", "")
+	source := &MySQLSource{}
+	if err := source.Init(map[string]interface{}{"query": "SELECT 1"}, orderModel); err == nil {
+		t.Error("expected error without MYSQL_DSN")
+	}
+}
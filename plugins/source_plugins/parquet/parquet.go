@@ -0,0 +1,341 @@
+package parquet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/plugins"
+	goparquet "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	log "github.com/sirupsen/logrus"
+)
+
+type parquetConfig struct {
+	fileio.FileOptions `yaml:",inline"`
+	Timezone           string `yaml:"timezone"`
+	BatchSize          int    `yaml:"batch_size"`
+}
+
+// ParquetSource reads Parquet files. Nested groups are addressed by their
+// dotted path, e.g. `customer.id`, repeated columns are read as JSON arrays.
+type ParquetSource struct {
+	Model     *models.Model
+	Mapping   *mapping.Mapping
+	Convert   convert.Options
+	BatchSize int
+	*fileio.Files
+}
+
+func (s *ParquetSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := parquetConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid parquet config: %v", err)
+	}
+
+	var err error
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+	s.Convert.DateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+	s.BatchSize = c.BatchSize
+	if s.BatchSize <= 0 {
+		s.BatchSize = 1000
+	}
+
+	s.Files, err = fileio.NewFiles(c.FileOptions)
+	return err
+}
+
+func (s *ParquetSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.Read(params.FilePath, func(file fileio.File, emit fileio.Emit) error {
+		return s.readFile(file.Path, emit)
+	})
+}
+
+func (s *ParquetSource) readFile(path string, fn func(record map[string]interface{})) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return s.read(file, info.Size(), fn)
+}
+
+type leaf struct {
+	name     string
+	node     goparquet.Node
+	repeated bool
+}
+
+// read decodes the rows of a Parquet file in batches and passes the
+// transformed records to fn. Records that can not be transformed are logged
+// and skipped.
+func (s *ParquetSource) read(r io.ReaderAt, size int64, fn func(record map[string]interface{})) error {
+	file, err := goparquet.OpenFile(r, size)
+	if err != nil {
+		return err
+	}
+
+	schema := file.Schema()
+	var leaves []leaf
+	for _, path := range schema.Columns() {
+		column, _ := schema.Lookup(path...)
+		leaves = append(leaves, leaf{
+			name:     strings.Join(path, "."),
+			node:     column.Node,
+			repeated: column.MaxRepetitionLevel > 0,
+		})
+	}
+	s.checkTypes(leaves)
+
+	reader := goparquet.NewReader(file)
+	defer reader.Close()
+
+	rows := make([]goparquet.Row, s.BatchSize)
+	line := 0
+	for {
+		n, err := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			line++
+			record, err := s.Transform(rowRecord(leaves, row))
+			if err != nil {
+				log.WithFields(log.Fields{
+					"row":   line,
+					"error": err,
+				}).Error("Error transforming record")
+				s.Files.Skip()
+				continue
+			}
+			fn(record)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkTypes warns about model columns whose type does not match the
+// Parquet column, the values are still converted.
+func (s *ParquetSource) checkTypes(leaves []leaf) {
+	types := make(map[string]models.ColumnType, len(leaves))
+	for _, l := range leaves {
+		types[l.name] = ColumnType(l.node)
+	}
+	for _, column := range s.Model.Columns {
+		field := s.Mapping.Field(column.Name)
+		columnType, ok := types[field.Name]
+		if !ok || columnType == column.Type {
+			continue
+		}
+		if column.Type == models.DateTimeNullable && columnType == models.DateTime {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"column":       column.Name,
+			"type":         column.Type.String(),
+			"parquet_type": columnType.String(),
+		}).Warn("Column type differs from the parquet type")
+	}
+}
+
+// rowRecord turns a row into a record keyed by the column paths.
+func rowRecord(leaves []leaf, row goparquet.Row) map[string]interface{} {
+	record := make(map[string]interface{}, len(leaves))
+	for _, v := range row {
+		l := leaves[v.Column()]
+		if !l.repeated {
+			record[l.name] = Value(l.node, v)
+			continue
+		}
+		items, _ := record[l.name].([]interface{})
+		if items == nil {
+			items = []interface{}{}
+		}
+		if !v.IsNull() {
+			items = append(items, Value(l.node, v))
+		}
+		record[l.name] = items
+	}
+	return record
+}
+
+// ColumnType maps the type of a Parquet column onto the model column types.
+func ColumnType(node goparquet.Node) models.ColumnType {
+	logical := node.Type().LogicalType()
+	switch {
+	case logical != nil && logical.Timestamp != nil, logical != nil && logical.Date != nil:
+		if node.Optional() {
+			return models.DateTimeNullable
+		}
+		return models.DateTime
+	case logical != nil && logical.Json != nil:
+		return models.JSON
+	case logical != nil && logical.Decimal != nil:
+		return models.Float
+	case logical != nil && (logical.UTF8 != nil || logical.Enum != nil || logical.UUID != nil):
+		return models.String
+	case logical != nil && logical.Integer != nil:
+		if logical.Integer.BitWidth == 64 {
+			return models.BigInt
+		}
+		return models.Int
+	}
+
+	switch node.Type().Kind() {
+	case goparquet.Boolean:
+		return models.Bool
+	case goparquet.Int32:
+		return models.Int
+	case goparquet.Int64:
+		return models.BigInt
+	case goparquet.Int96:
+		return models.DateTime
+	case goparquet.Float, goparquet.Double:
+		return models.Float
+	default:
+		return models.String
+	}
+}
+
+// Value returns the Go value of a Parquet value: timestamps and dates as
+// time.Time in UTC, decimals as json.Number, integers as int64 and binary
+// columns as strings.
+func Value(node goparquet.Node, v goparquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	logical := node.Type().LogicalType()
+
+	switch v.Kind() {
+	case goparquet.Boolean:
+		return v.Boolean()
+	case goparquet.Int32:
+		switch {
+		case logical != nil && logical.Date != nil:
+			return time.Unix(int64(v.Int32())*86400, 0).UTC()
+		case logical != nil && logical.Decimal != nil:
+			return decimal(big.NewInt(int64(v.Int32())), logical.Decimal)
+		}
+		return int64(v.Int32())
+	case goparquet.Int64:
+		switch {
+		case logical != nil && logical.Timestamp != nil:
+			return timestamp(v.Int64(), logical.Timestamp.Unit)
+		case logical != nil && logical.Decimal != nil:
+			return decimal(big.NewInt(v.Int64()), logical.Decimal)
+		}
+		return v.Int64()
+	case goparquet.Int96:
+		// legacy timestamps: nanoseconds of the day and the julian day
+		i96 := v.Int96()
+		nanos := int64(i96[1])<<32 | int64(i96[0])
+		days := int64(i96[2]) - 2440588
+		return time.Unix(days*86400, nanos).UTC()
+	case goparquet.Float:
+		return float64(v.Float())
+	case goparquet.Double:
+		return v.Double()
+	case goparquet.ByteArray, goparquet.FixedLenByteArray:
+		data := v.ByteArray()
+		switch {
+		case logical != nil && logical.Decimal != nil:
+			return decimal(twosComplement(data), logical.Decimal)
+		case logical != nil && logical.UUID != nil && len(data) == 16:
+			h := hex.EncodeToString(data)
+			return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+		}
+		return string(data)
+	}
+	return v.String()
+}
+
+func timestamp(value int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(value).UTC()
+	case unit.Nanos != nil:
+		return time.Unix(0, value).UTC()
+	default:
+		return time.UnixMicro(value).UTC()
+	}
+}
+
+// decimal formats an unscaled decimal, json.Number keeps the precision for
+// the conversion into the column type.
+func decimal(unscaled *big.Int, t *format.DecimalType) json.Number {
+	return json.Number(new(big.Float).SetPrec(128).Quo(
+		new(big.Float).SetInt(unscaled),
+		new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Scale)), nil)),
+	).Text('f', int(t.Scale)))
+}
+
+// twosComplement reads a big endian two's complement integer.
+func twosComplement(data []byte) *big.Int {
+	value := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return value
+}
+
+// Transform maps a record onto the model columns and converts the values.
+func (s *ParquetSource) Transform(row map[string]interface{}) (map[string]interface{}, error) {
+	record, err := s.Mapping.Record(s.Model, row)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *ParquetSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("parquet", func() plugins.Source {
+		return &ParquetSource{}
+	})
+}
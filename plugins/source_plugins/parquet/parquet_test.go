@@ -0,0 +1,145 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	goparquet "github.com/parquet-go/parquet-go"
+)
+
+type customer struct {
+	ID   int32  `parquet:"id"`
+	Name string `parquet:"name"`
+}
+
+type order struct {
+	ID        string     `parquet:"id"`
+	Amount    int64      `parquet:"amount,decimal(2:18)"`
+	Created   time.Time  `parquet:"created,timestamp(millisecond)"`
+	Day       int32      `parquet:"day,date"`
+	Paid      bool       `parquet:"paid"`
+	Quantity  *int64     `parquet:"quantity,optional"`
+	Customer  customer   `parquet:"customer"`
+	Tags      []string   `parquet:"tags,list"`
+	UpdatedAt *time.Time `parquet:"updated_at,optional"`
+}
+
+var orderModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "id", Type: models.String},
+		{Name: "amount", Type: models.Float},
+		{Name: "created", Type: models.DateTime},
+		{Name: "day", Type: models.DateTime},
+		{Name: "paid", Type: models.Bool},
+		{Name: "quantity", Type: models.BigInt},
+		{Name: "customer_id", Type: models.Int},
+		{Name: "customer_name", Type: models.String},
+		{Name: "tags", Type: models.JSON},
+		{Name: "updated_at", Type: models.DateTimeNullable},
+	},
+	Unique: []string{"id"},
+}
+
+func writeOrders(t *testing.T, path string, orders []order) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := goparquet.NewGenericWriter[order](&buf)
+	if _, err := writer.Write(orders); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchData(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	quantity := int64(3)
+	writeOrders(t, filepath.Join(dir, "orders.parquet"), []order{
+		{
+			ID: "A-1", Amount: 1999, Created: created, Day: 19967,
+			Paid: true, Quantity: &quantity, Customer: customer{ID: 7, Name: "Müller"}, Tags: []string{"b2b", "eu"},
+		},
+		{ID: "A-2", Amount: -550, Created: created, Day: 19967, Customer: customer{ID: 8}},
+	})
+
+	source := &ParquetSource{}
+	err := source.Init(map[string]interface{}{
+		"path": dir,
+		"mapping": map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{
+				"customer_id":   "customer.id",
+				"customer_name": "customer.name",
+				"tags":          "tags.list.element",
+			},
+		},
+	}, orderModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	records, err := source.FetchData(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	want := map[string]interface{}{
+		"id": "A-1", "amount": 19.99, "paid": true, "quantity": int64(3),
+		"customer_id": 7, "customer_name": "Müller", "tags": `["b2b","eu"]`, "updated_at": nil,
+	}
+	for column, value := range want {
+		if !reflect.DeepEqual(records[0][column], value) {
+			t.Errorf("column %s = %#v, want %#v", column, records[0][column], value)
+		}
+	}
+	if got, ok := records[0]["created"].(time.Time); !ok || !got.Equal(created) {
+		t.Errorf("created = %v, want %v", records[0]["created"], created)
+	}
+	if got, ok := records[0]["day"].(time.Time); !ok || !got.Equal(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("day = %v", records[0]["day"])
+	}
+	if records[1]["amount"] != -5.5 || records[1]["quantity"] != nil || records[1]["tags"] != "[]" {
+		t.Errorf("unexpected second record: %v", records[1])
+	}
+}
+
+func TestColumnType(t *testing.T) {
+	schema := goparquet.SchemaOf(order{})
+	want := map[string]models.ColumnType{
+		"id":         models.String,
+		"amount":     models.Float,
+		"created":    models.DateTime,
+		"day":        models.DateTime,
+		"paid":       models.Bool,
+		"quantity":   models.BigInt,
+		"updated_at": models.DateTimeNullable,
+	}
+	for name, columnType := range want {
+		column, ok := schema.Lookup(name)
+		if !ok {
+			t.Fatalf("column %s not found", name)
+		}
+		if got := ColumnType(column.Node); got != columnType {
+			t.Errorf("ColumnType(%s) = %s, want %s", name, got, columnType)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	if got := Value(goparquet.Decimal(2, 10, goparquet.FixedLenByteArrayType(4)), goparquet.ValueOf([]byte{0xff, 0xff, 0xff, 0x85})); got != json.Number("-1.23") {
+		t.Errorf("decimal = %v", got)
+	}
+}
@@ -0,0 +1,165 @@
+package sql_api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PaginationNone   = ""
+	PaginationOffset = "offset"
+	PaginationCursor = "cursor"
+)
+
+// Pagination configures how large result sets are fetched in chunks.
+//
+// With the offset strategy the query must use the `{{ .limit }}` and
+// `{{ .offset }}` variables (or the legacy `{limit}` and `{offset}`
+// placeholders), with the cursor strategy the token returned in
+// `cursor_field` is sent back in the request body as `cursor_param`.
+//
+//	pagination:
+//	  type: offset
+//	  limit: 5000
+//
+//	pagination:
+//	  type: cursor
+//	  cursor_field: next_page_token
+//	  cursor_param: page_token
+type Pagination struct {
+	Type        string `yaml:"type"`
+	Limit       int    `yaml:"limit"`
+	CursorField string `yaml:"cursor_field"`
+	CursorParam string `yaml:"cursor_param"`
+}
+
+func ParsePagination(raw interface{}) (Pagination, error) {
+	pagination := Pagination{}
+	if err := config.Decode(raw, &pagination); err != nil {
+		return Pagination{}, fmt.Errorf("invalid pagination config: %v", err)
+	}
+
+	switch pagination.Type {
+	case PaginationNone:
+	case PaginationOffset:
+		if pagination.Limit <= 0 {
+			pagination.Limit = 1000
+		}
+	case PaginationCursor:
+		if pagination.CursorField == "" {
+			pagination.CursorField = "next_cursor"
+		}
+		if pagination.CursorParam == "" {
+			pagination.CursorParam = "cursor"
+		}
+	default:
+		return Pagination{}, fmt.Errorf("invalid pagination type: %s", pagination.Type)
+	}
+
+	return pagination, nil
+}
+
+// fetchPages renders the query and requests it page by page, handing every
+// record to fn as soon as it is decoded. The offset strategy renders the
+// query for every page with the `limit` and `offset` variables.
+func (s *SQLAPISource) fetchPages(data map[string]interface{}, fn func(item interface{}) error) error {
+	switch s.Pagination.Type {
+	case PaginationOffset:
+		if !s.Template.Uses("offset") {
+			return errors.New("offset pagination requires an offset placeholder in the query")
+		}
+		data = tmpl.Data(data, map[string]interface{}{"limit": s.Pagination.Limit})
+		for offset := 0; ; offset += s.Pagination.Limit {
+			data["offset"] = offset
+			query, err := s.Template.Render(data)
+			if err != nil {
+				return err
+			}
+
+			size, _, err := s.fetchPage(map[string]interface{}{"query": query}, fn)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"offset": offset,
+				"limit":  s.Pagination.Limit,
+				"size":   size,
+			}).Debug("SQLAPISource:fetched page")
+			if size == 0 {
+				return nil
+			}
+		}
+	case PaginationCursor:
+		query, err := s.Template.Render(data)
+		if err != nil {
+			return err
+		}
+		body := map[string]interface{}{"query": query}
+		for pageNumber := 1; ; pageNumber++ {
+			size, meta, err := s.fetchPage(body, fn)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"page": pageNumber,
+				"size": size,
+			}).Debug("SQLAPISource:fetched page")
+			if size == 0 {
+				return nil
+			}
+
+			cursor, ok := meta[s.Pagination.CursorField]
+			if !ok || cursor == nil || cursor == "" {
+				return nil
+			}
+			body[s.Pagination.CursorParam] = cursor
+		}
+	default:
+		query, err := s.Template.Render(data)
+		if err != nil {
+			return err
+		}
+		_, _, err = s.fetchPage(map[string]interface{}{"query": query}, fn)
+		return err
+	}
+}
+
+// fetchPage requests a single page and streams its records to fn. Only the
+// request itself is retried: once records have been handed out a failure
+// would otherwise duplicate them. It returns the number of records and the
+// remaining top level fields of the response.
+func (s *SQLAPISource) fetchPage(body map[string]interface{}, fn func(item interface{}) error) (int, map[string]interface{}, error) {
+	var resp *http.Response
+	err := s.Retry.Do("sql_api", func() error {
+		var err error
+		resp, err = s.request(body)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	reader, err := responseBody(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := 0
+	meta, err := decodeResults(reader, s.responseFormat(resp), func(item interface{}) error {
+		size++
+		return fn(item)
+	})
+	if closeErr := reader.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error reading response: %v", closeErr)
+	}
+	if err != nil {
+		return size, nil, err
+	}
+	return size, meta, nil
+}
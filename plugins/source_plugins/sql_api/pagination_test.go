@@ -0,0 +1,170 @@
+package sql_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+)
+
+func mustParse(t *testing.T, query string) *tmpl.Template {
+	template, err := tmpl.Parse("query", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func TestFetchPagesOffset(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		query := body["query"].(string)
+		queries = append(queries, query)
+
+		results := []interface{}{}
+		if !strings.HasSuffix(query, "OFFSET 4") {
+			results = append(results, map[string]interface{}{"id": "1"}, map[string]interface{}{"id": "2"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer server.Close()
+
+	source := &SQLAPISource{
+		Endpoint:   server.URL,
+		Auth:       &httpauth.Auth{},
+		Retry:      retry.DefaultPolicy(),
+		Query:      "SELECT id FROM t LIMIT {limit} OFFSET {offset}",
+		Pagination: Pagination{Type: PaginationOffset, Limit: 2},
+	}
+	source.Template = mustParse(t, legacyPlaceholders.Replace(source.Query))
+
+	total := 0
+	err := source.fetchPages(map[string]interface{}{}, func(item interface{}) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("fetchPages() error = %v", err)
+	}
+	if total != 4 {
+		t.Errorf("expected 4 records, got %d", total)
+	}
+	want := []string{
+		"SELECT id FROM t LIMIT 2 OFFSET 0",
+		"SELECT id FROM t LIMIT 2 OFFSET 2",
+		"SELECT id FROM t LIMIT 2 OFFSET 4",
+	}
+	if fmt.Sprint(queries) != fmt.Sprint(want) {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestFetchPagesCursor(t *testing.T) {
+	pages := map[string]map[string]interface{}{
+		"": {
+			"results":    []interface{}{map[string]interface{}{"id": "1"}},
+			"next_token": "b",
+		},
+		"b": {
+			"results":    []interface{}{map[string]interface{}{"id": "2"}},
+			"next_token": nil,
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		token, _ := body["page_token"].(string)
+		json.NewEncoder(w).Encode(pages[token])
+	}))
+	defer server.Close()
+
+	source := &SQLAPISource{
+		Endpoint: server.URL,
+		Auth:     &httpauth.Auth{},
+		Retry:    retry.DefaultPolicy(),
+		Pagination: Pagination{
+			Type:        PaginationCursor,
+			CursorField: "next_token",
+			CursorParam: "page_token",
+		},
+		Template: mustParse(t, "SELECT id FROM t"),
+	}
+
+	total := 0
+	err := source.fetchPages(map[string]interface{}{}, func(item interface{}) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("fetchPages() error = %v", err)
+	}
+	if total != 2 {
+		t.Errorf("expected 2 records, got %d", total)
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	if _, err := ParsePagination(map[interface{}]interface{}{"type": "pages"}); err == nil {
+		t.Error("expected error for unknown pagination type")
+	}
+
+	p, err := ParsePagination(map[interface{}]interface{}{"type": "offset"})
+	if err != nil {
+		t.Fatalf("ParsePagination() error = %v", err)
+	}
+	if p.Limit != 1000 {
+		t.Errorf("expected default limit 1000, got %d", p.Limit)
+	}
+}
+
+func TestFetchDataTemplateData(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		query = body["query"].(string)
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{}})
+	}))
+	defer server.Close()
+
+	source := &SQLAPISource{
+		Endpoint: server.URL,
+		Auth:     &httpauth.Auth{},
+		Retry:    retry.DefaultPolicy(),
+		Template: mustParse(t, `SELECT '{{ .name }}', {{ .mandant }} WHERE t >= '{{ .start_at | date "2006-01-02" }}'`),
+	}
+	_, err := source.FetchData(map[string]interface{}{
+		"name":     "belege",
+		"start_at": time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		"end_at":   time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+		"vars":     map[string]interface{}{"mandant": 7},
+	})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if want := "SELECT 'belege', 7 WHERE t >= '2024-09-01'"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+}
+
+func TestFetchPagesOffsetWithoutPlaceholder(t *testing.T) {
+	source := &SQLAPISource{
+		Query:      "SELECT t.offset_days FROM t LIMIT {limit}",
+		Pagination: Pagination{Type: PaginationOffset, Limit: 2},
+	}
+	source.Template = mustParse(t, legacyPlaceholders.Replace(source.Query))
+
+	err := source.fetchPages(map[string]interface{}{}, func(item interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "offset placeholder") {
+		t.Errorf("fetchPages() error = %v", err)
+	}
+}
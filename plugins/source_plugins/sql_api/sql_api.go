@@ -3,71 +3,95 @@ package sql_api
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/httpauth"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/pkg/retry"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
 	"github.com/Talk-Point/databridge/plugins"
 	log "github.com/sirupsen/logrus"
 )
 
-type SQLAPIParams struct {
-	StartAt time.Time
-	EndAt   time.Time
+type SQLAPISource struct {
+	Model      *models.Model
+	Endpoint   string
+	Auth       *httpauth.Auth
+	Query      string
+	Template   *tmpl.Template
+	Date       string
+	Retry      *retry.Policy
+	Pagination Pagination
+	Mapping    *mapping.Mapping
+	// ResponseFormat is json or ndjson, empty to detect it from the
+	// Content-Type of the response.
+	ResponseFormat string
 }
 
-func ParseOpts(opts map[string]interface{}) (SQLAPIParams, error) {
-	params := SQLAPIParams{}
-	if startAt, ok := opts["start_at"]; ok {
-		if startAtTime, ok := startAt.(time.Time); ok {
-			params.StartAt = startAtTime
-		} else {
-			return SQLAPIParams{}, errors.New("start_at is not of type time.Time")
-		}
-	} else {
-		return SQLAPIParams{}, errors.New("start_at is missing")
+func (s *SQLAPISource) Init(config map[string]interface{}, model *models.Model) error {
+	s.Model = model
+	s.Endpoint = config["endpoint"].(string)
+	s.Query = config["query"].(string)
+	template, err := tmpl.Parse("query", legacyPlaceholders.Replace(s.Query))
+	if err != nil {
+		return err
 	}
+	s.Template = template
 
-	if endAt, ok := opts["end_at"]; ok {
-		if endAtTime, ok := endAt.(time.Time); ok {
-			params.EndAt = endAtTime
-		} else {
-			return SQLAPIParams{}, errors.New("end_at is not of type time.Time")
+	// without an auth block the token is read from API_TOKEN and sent as
+	// X-API-KEY header
+	authConfig, ok := config["auth"]
+	if !ok {
+		authConfig = map[string]interface{}{
+			"type": httpauth.APIKey,
+			"key":  map[string]interface{}{"env": "API_TOKEN"},
 		}
-	} else {
-		return SQLAPIParams{}, errors.New("end_at is missing")
+	}
+	s.Auth, err = httpauth.Parse(authConfig)
+	if err != nil {
+		return err
 	}
 
-	return params, nil
-}
+	policy, err := retry.ParsePolicy(config)
+	if err != nil {
+		return err
+	}
+	s.Retry = policy
 
-type SQLAPISource struct {
-	Model    *models.Model
-	Endpoint string
-	APIToken string
-	Query    string
-	Date     string
-}
+	s.Pagination, err = ParsePagination(config["pagination"])
+	if err != nil {
+		return err
+	}
 
-func (s *SQLAPISource) Init(config map[string]interface{}, model *models.Model) error {
-	s.Model = model
-	s.Endpoint = config["endpoint"].(string)
-	s.APIToken = os.Getenv("API_TOKEN")
-	if s.APIToken == "" {
-		return fmt.Errorf("API_TOKEN environment variable is required")
+	s.Mapping, err = mapping.Parse(config["mapping"], model)
+	if err != nil {
+		return err
+	}
+
+	if format, ok := config["response_format"].(string); ok {
+		if format != ResponseFormatJSON && format != ResponseFormatNDJSON {
+			return fmt.Errorf("invalid response_format: %s", format)
+		}
+		s.ResponseFormat = format
 	}
-	s.Query = config["query"].(string)
 	return nil
 }
 
+// legacyPlaceholders rewrites the placeholders of queries written before
+// templates were supported.
+var legacyPlaceholders = strings.NewReplacer(
+	"{start_at}", `{{ date "german" .start_at }}`,
+	"{end_at}", `{{ date "german" .end_at }}`,
+	"{limit}", "{{ .limit }}",
+	"{offset}", "{{ .offset }}",
+)
+
 func (s *SQLAPISource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
-	params, err := ParseOpts(opts)
+	params, err := plugins.ParseFetchOpts(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -79,12 +103,35 @@ func (s *SQLAPISource) FetchData(opts map[string]interface{}) ([]map[string]inte
 		"end_at":   endAt,
 	}).Info("SQLAPISource:FetchData")
 
-	query := s.Query
-	query = strings.ReplaceAll(query, "{start_at}", params.StartAt.Format("02.01.2006 15:04:05"))
-	query = strings.ReplaceAll(query, "{end_at}", params.EndAt.Format("02.01.2006 15:04:05"))
+	data := params.TemplateData()
 
+	// Stream the pages and transform the records one at a time
+	total_errored := 0
+	records := make([]map[string]interface{}, 0)
+	err = s.fetchPages(data, func(item interface{}) error {
+		transformedRecord, err := s.Transform(item)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"item": item,
+				"err":  err,
+			}).Errorf("Error transforming record: %v", err)
+			total_errored++
+			return nil
+		}
+		records = append(records, transformedRecord)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// request sends the query and returns the response once the status is
+// known to be successful. The caller is responsible for closing the body.
+func (s *SQLAPISource) request(reqBody map[string]interface{}) (*http.Response, error) {
 	// Prepare the request
-	reqBody := map[string]string{"query": query}
 	reqJSON, _ := json.Marshal(reqBody)
 
 	req, err := http.NewRequest("POST", s.Endpoint, bytes.NewBuffer(reqJSON))
@@ -92,123 +139,53 @@ func (s *SQLAPISource) FetchData(opts map[string]interface{}) ([]map[string]inte
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-KEY", s.APIToken)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if err := s.Auth.Apply(req); err != nil {
+		return nil, err
+	}
 
 	// Make the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.Auth.Client().Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch data: %s", string(bodyBytes))
-	}
-
-	// Parse response
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract data
-	data, ok := result["results"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+		return nil, fmt.Errorf("failed to fetch data: %w", &retry.StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+		})
 	}
 
-	// Convert to []map[string]interface{}
-	total_errored := 0
-	records := make([]map[string]interface{}, 0, len(data))
-	for _, item := range data {
-		transformedRecord, err := s.Transform(item)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"item": item,
-				"err":  err,
-			}).Errorf("Error transforming record: %v", err)
-			total_errored++
-			continue
-		}
-		records = append(records, transformedRecord)
-	}
-
-	return records, nil
+	return resp, nil
 }
 
 func (s *SQLAPISource) Close() error {
 	return nil
 }
 
-func convert(value string, columnType models.ColumnType) (interface{}, error) {
-	switch columnType {
-	case models.String:
-		return value, nil
-	case models.BigInt:
-		// convert string to bigint
-		value, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		return value, nil
-	case models.Float:
-		// convert string to float
-		// replace "," with "." to parse float
-		value, err := strconv.ParseFloat(strings.Replace(value, ",", ".", -1), 64)
-		if err != nil {
-			return nil, err
-		}
-		return value, nil
-	case models.DateTime:
-		// convert string to datetime
-		loc, err := time.LoadLocation("Europe/Berlin")
-		if err != nil {
-			return nil, err
-		}
-		value, err := time.ParseInLocation("02.01.2006 15:04:05", value, loc)
-		if err != nil {
-			return nil, err
-		}
-		return value, nil
-	case models.DateTimeNullable:
-		// convert string to datetime
-		if value == "" {
-			return nil, nil
-		}
-		loc, err := time.LoadLocation("Europe/Berlin")
-		if err != nil {
-			return nil, err
-		}
-		value, err := time.ParseInLocation("02.01.2006 15:04:05", value, loc)
-		if err != nil {
-			return nil, err
-		}
-		return value, nil
-	case models.Int:
-		// convert string to int
-		value, err := strconv.Atoi(value)
-		if err != nil {
-			return nil, err
-		}
-		return value, nil
-	default:
-		return nil, fmt.Errorf("invalid column type: %s", columnType)
-	}
-}
-
 func (s *SQLAPISource) Transform(item interface{}) (map[string]interface{}, error) {
-	record, ok := item.(map[string]interface{})
+	source, ok := item.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("unexpected record format")
 	}
 
+	record, err := s.Mapping.Record(s.Model, source)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, column := range s.Model.Columns {
-		if _, ok := record[column.Name]; ok {
-			data, err := convert(record[column.Name].(string), column.Type)
+		if value, ok := record[column.Name]; ok {
+			data, err := convertValue(value, column.Type)
 			if err != nil {
+				log.WithFields(log.Fields{
+					"column": column.Name,
+					"value":  value,
+					"error":  err,
+				}).Warn("Column value could not be converted")
 				continue
 			}
 			record[column.Name] = data
@@ -0,0 +1,171 @@
+package sql_api
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+)
+
+var typedModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "mandant", Type: models.Int},
+		{Name: "bel_id", Type: models.BigInt},
+		{Name: "nettobetrag_ew", Type: models.Float},
+		{Name: "user_bezahlt", Type: models.Bool},
+		{Name: "user_kom_zeit", Type: models.DateTimeNullable},
+		{Name: "time", Type: models.DateTime},
+		{Name: "kopftext", Type: models.String},
+		{Name: "meta", Type: models.JSON},
+	},
+	Unique: []string{"bel_id", "time"},
+}
+
+func berlin(t *testing.T, value string) time.Time {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := time.ParseInLocation("02.01.2006 15:04:05", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestTransformFixtures(t *testing.T) {
+	first := map[string]interface{}{
+		"mandant":        1,
+		"bel_id":         int64(9007199254740993),
+		"nettobetrag_ew": 119.95,
+		"user_bezahlt":   true,
+		"user_kom_zeit":  nil,
+		"time":           berlin(t, "25.09.2024 08:15:00"),
+		"kopftext":       "Auftrag",
+		"meta":           `{"source":"shop","tags":["a","b"]}`,
+	}
+
+	tests := []struct {
+		name    string
+		fixture string
+		format  string
+		want    []map[string]interface{}
+	}{
+		{
+			name:    "json response",
+			fixture: "testdata/typed_results.json",
+			format:  ResponseFormatJSON,
+			want: []map[string]interface{}{
+				first,
+				{
+					"mandant":        1,
+					"bel_id":         int64(4711),
+					"nettobetrag_ew": 19.99,
+					"user_bezahlt":   false,
+					"user_kom_zeit":  berlin(t, "25.09.2024 09:00:00"),
+					"time":           berlin(t, "25.09.2024 08:30:00"),
+					"kopftext":       "42",
+					"meta":           nil,
+				},
+				{
+					"mandant":        1,
+					"bel_id":         int64(1000),
+					"nettobetrag_ew": 0.0,
+					"user_bezahlt":   false,
+					"user_kom_zeit":  nil,
+					"time":           berlin(t, "25.09.2024 08:45:00"),
+					"kopftext":       "false",
+					"meta":           "[1,2]",
+				},
+			},
+		},
+		{
+			name:    "ndjson response",
+			fixture: "testdata/typed_results.ndjson",
+			format:  ResponseFormatNDJSON,
+			want:    []map[string]interface{}{first},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Open(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			source := &SQLAPISource{Model: typedModel, Mapping: &mapping.Mapping{}}
+			var got []map[string]interface{}
+			_, err = decodeResults(file, tt.format, func(item interface{}) error {
+				record, err := source.Transform(item)
+				if err != nil {
+					return err
+				}
+				got = append(got, record)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("decodeResults() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d", len(tt.want), len(got))
+			}
+			for i, want := range tt.want {
+				for column, wantValue := range want {
+					gotValue := got[i][column]
+					if wantTime, ok := wantValue.(time.Time); ok {
+						gotTime, ok := gotValue.(time.Time)
+						if !ok || !gotTime.Equal(wantTime) {
+							t.Errorf("record %d column %s = %v, want %v", i, column, gotValue, wantValue)
+						}
+						continue
+					}
+					if !reflect.DeepEqual(gotValue, wantValue) {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, gotValue, wantValue)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      interface{}
+		columnType models.ColumnType
+		want       interface{}
+		wantErr    bool
+	}{
+		{"number to string", json.Number("12.50"), models.String, "12.50", false},
+		{"large bigint keeps precision", json.Number("9223372036854775807"), models.BigInt, int64(9223372036854775807), false},
+		{"float number to bigint", json.Number("1.5"), models.BigInt, nil, true},
+		{"int overflow", json.Number("3000000000"), models.Int, nil, true},
+		{"number to datetime", json.Number("1"), models.DateTime, nil, true},
+		{"bool to int", true, models.Int, 1, false},
+		{"bool to float", true, models.Float, nil, true},
+		{"object to int", map[string]interface{}{}, models.Int, nil, true},
+		{"null to datetime", nil, models.DateTime, nil, false},
+		{"invalid float string", "abc", models.Float, nil, true},
+		{"json string", `{"a": 1}`, models.JSON, `{"a": 1}`, false},
+		{"invalid json string", `{"a"`, models.JSON, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertValue(tt.value, tt.columnType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
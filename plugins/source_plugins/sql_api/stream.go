@@ -0,0 +1,144 @@
+package sql_api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Talk-Point/databridge/pkg/jsonpath"
+)
+
+const (
+	ResponseFormatJSON   = "json"
+	ResponseFormatNDJSON = "ndjson"
+)
+
+// responseFormat returns the configured format or derives it from the
+// Content-Type of the response.
+func (s *SQLAPISource) responseFormat(resp *http.Response) string {
+	if s.ResponseFormat != "" {
+		return s.ResponseFormat
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl") {
+		return ResponseFormatNDJSON
+	}
+	return ResponseFormatJSON
+}
+
+// responseBody returns the body of the response, transparently decompressing
+// gzip encoded bodies. Compression is detected by the gzip magic bytes, so
+// gateways that omit the Content-Encoding header work as well. The caller
+// closes the returned reader after decoding, which reports truncated or
+// corrupt gzip bodies; the response body itself is not closed.
+func responseBody(body io.Reader) (io.ReadCloser, error) {
+	reader := bufio.NewReader(body)
+	magic, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return gzipBody{gz}, nil
+	}
+	return io.NopCloser(reader), nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+}
+
+// Close reads the rest of the stream, the checksum of a gzip body is only
+// verified at its end, which the JSON decoder never reaches.
+func (b gzipBody) Close() error {
+	if _, err := io.Copy(io.Discard, b.Reader); err != nil {
+		return err
+	}
+	return b.Reader.Close()
+}
+
+// decodeResults streams the records of a response to fn one at a time.
+//
+// For JSON responses the decoder walks to the top level `results` array and
+// decodes its elements one by one; all other top level keys are collected
+// and returned (e.g. for pagination cursors). For NDJSON every line is a
+// record. Numbers are decoded as json.Number to keep their precision.
+func decodeResults(r io.Reader, format string, fn func(item interface{}) error) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	if format == ResponseFormatNDJSON {
+		for {
+			var item interface{}
+			err := decoder.Decode(&item)
+			if err == io.EOF {
+				return map[string]interface{}{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := fn(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := jsonpath.ExpectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	meta := make(map[string]interface{})
+	found := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", token)
+		}
+
+		if key != "results" {
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			meta[key] = value
+			continue
+		}
+
+		if err := jsonpath.ExpectDelim(decoder, '['); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			var item interface{}
+			if err := decoder.Decode(&item); err != nil {
+				return nil, err
+			}
+			if err := fn(item); err != nil {
+				return nil, err
+			}
+		}
+		if err := jsonpath.ExpectDelim(decoder, ']'); err != nil {
+			return nil, err
+		}
+		found = true
+	}
+
+	if err := jsonpath.ExpectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("unexpected response format")
+	}
+	return meta, nil
+}
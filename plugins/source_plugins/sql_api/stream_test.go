@@ -0,0 +1,129 @@
+package sql_api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		format   string
+		want     int
+		wantMeta map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "json results with metadata",
+			body:     `{"count": 2, "results": [{"id": "1"}, {"id": "2"}], "next_cursor": "abc"}`,
+			format:   ResponseFormatJSON,
+			want:     2,
+			wantMeta: map[string]interface{}{"count": json.Number("2"), "next_cursor": "abc"},
+		},
+		{
+			name:     "empty results",
+			body:     `{"results": []}`,
+			format:   ResponseFormatJSON,
+			want:     0,
+			wantMeta: map[string]interface{}{},
+		},
+		{
+			name:    "missing results",
+			body:    `{"error": "nope"}`,
+			format:  ResponseFormatJSON,
+			wantErr: true,
+		},
+		{
+			name:    "not an object",
+			body:    `[{"id": "1"}]`,
+			format:  ResponseFormatJSON,
+			wantErr: true,
+		},
+		{
+			name:     "ndjson",
+			body:     "{\"id\": \"1\"}\n{\"id\": \"2\"}\n{\"id\": \"3\"}\n",
+			format:   ResponseFormatNDJSON,
+			want:     3,
+			wantMeta: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := 0
+			meta, err := decodeResults(strings.NewReader(tt.body), tt.format, func(item interface{}) error {
+				if _, ok := item.(map[string]interface{}); !ok {
+					t.Errorf("unexpected item %v", item)
+				}
+				count++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeResults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if count != tt.want {
+				t.Errorf("expected %d records, got %d", tt.want, count)
+			}
+			if len(meta) != len(tt.wantMeta) {
+				t.Errorf("meta = %v, want %v", meta, tt.wantMeta)
+			}
+			for key, value := range tt.wantMeta {
+				if meta[key] != value {
+					t.Errorf("meta[%s] = %v, want %v", key, meta[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseBodyGzip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(`{"results": [{"id": "1"}]}`))
+	writer.Close()
+
+	reader, err := responseBody(&buf)
+	if err != nil {
+		t.Fatalf("responseBody() error = %v", err)
+	}
+	defer reader.Close()
+
+	count := 0
+	_, err = decodeResults(reader, ResponseFormatJSON, func(item interface{}) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeResults() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 record, got %d", count)
+	}
+}
+
+func TestResponseBodyTruncatedGzip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(`{"results": [{"id": "1"}]}`))
+	writer.Close()
+	// drop the checksum trailer
+	data := buf.Bytes()[:buf.Len()-8]
+
+	reader, err := responseBody(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("responseBody() error = %v", err)
+	}
+	if _, err := decodeResults(reader, ResponseFormatJSON, func(item interface{}) error { return nil }); err != nil {
+		t.Fatalf("decodeResults() error = %v", err)
+	}
+	if err := reader.Close(); err == nil {
+		t.Error("Close() of a truncated body expected error")
+	}
+}
@@ -0,0 +1,19 @@
+package sql_api
+
+import (
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+)
+
+// convertOptions parses the German datetime format the ERP returns.
+var convertOptions = convert.Options{
+	DateLayouts: []string{"02.01.2006 15:04:05"},
+}
+
+// convertValue converts a decoded JSON value into the Go type of the column.
+// Strings are parsed like before, numbers are decoded as json.Number so
+// bigint columns keep their precision, null becomes nil and objects or
+// arrays are stored as JSON documents.
+func convertValue(value interface{}, columnType models.ColumnType) (interface{}, error) {
+	return convert.Value(value, columnType, convertOptions)
+}
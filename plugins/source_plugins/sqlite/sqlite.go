@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/pkg/sqlitedb"
+	"github.com/Talk-Point/databridge/pkg/tmpl"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+type sqliteConfig struct {
+	Path        string   `yaml:"path"`
+	Query       string   `yaml:"query"`
+	DateFormats []string `yaml:"date_formats"`
+	Timezone    string   `yaml:"timezone"`
+}
+
+// SQLiteSource runs a query against a SQLite database file. The query is a
+// template like the sql_api queries, so the window can be used as filter.
+type SQLiteSource struct {
+	Model    *models.Model
+	DB       *sql.DB
+	Template *tmpl.Template
+	Mapping  *mapping.Mapping
+	Convert  convert.Options
+}
+
+func (s *SQLiteSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := sqliteConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid sqlite config: %v", err)
+	}
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+	if c.Query == "" {
+		return errors.New("query is required")
+	}
+
+	var err error
+	if s.Template, err = tmpl.Parse("query", c.Query); err != nil {
+		return err
+	}
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+
+	// datetimes are text in SQLite, the first layout is the one the driver
+	// writes time.Time values with
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	db, err := sqlitedb.Open(c.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", c.Path, err)
+	}
+	s.DB = db
+	return nil
+}
+
+func (s *SQLiteSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := s.Template.Render(params.TemplateData())
+	if err != nil {
+		return nil, fmt.Errorf("error rendering query: %v", err)
+	}
+	log.WithFields(log.Fields{
+		"start_at": params.StartAt,
+		"end_at":   params.EndAt,
+		"query":    query,
+	}).Info("SQLiteSource:FetchData")
+
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	index := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		index++
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// TEXT is scanned as string, BLOB as []byte
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+				continue
+			}
+			row[column] = values[i]
+		}
+
+		record, err := s.Transform(row)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"row":   index,
+				"error": err,
+			}).Error("Error transforming record")
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Transform maps a row onto the model columns and converts the values.
+func (s *SQLiteSource) Transform(row map[string]interface{}) (map[string]interface{}, error) {
+	record, err := s.Mapping.Record(s.Model, row)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name]
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *SQLiteSource) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
+
+func init() {
+	plugins.RegisterSource("sqlite", func() plugins.Source {
+		return &SQLiteSource{}
+	})
+}
@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var orderModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "time", Type: models.DateTime},
+		{Name: "order_id", Type: models.BigInt},
+		{Name: "customer", Type: models.String},
+		{Name: "amount", Type: models.Float},
+		{Name: "paid", Type: models.Bool},
+	},
+	Unique: []string{"order_id"},
+}
+
+func createDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE orders (created TEXT, id INTEGER, customer TEXT, amount REAL, paid BOOLEAN, booked DATETIME);
+		INSERT INTO orders VALUES
+			('2024-09-01 08:00:00', 1, 'Müller', 10.5, 1, NULL),
+			('2024-09-01 12:00:00', 2, 'Schmidt', 3, 0, NULL),
+			('2024-09-02 08:00:00', 3, 'Meier', 1, 0, NULL),
+			('kaputt', 4, 'Fehler', 1, 0, NULL);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetchData(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	path := createDB(t)
+
+	source := &SQLiteSource{}
+	err := source.Init(map[string]interface{}{
+		"path": path,
+		"query": `SELECT created, id, customer, amount, paid FROM orders
+			WHERE created >= {{ .start_at | date "2006-01-02" | sqlString }}
+			  AND created < {{ .end_at | date "2006-01-02" | sqlString }}
+			  AND customer <> {{ sqlString .exclude }}
+			ORDER BY id`,
+		"mapping": map[interface{}]interface{}{
+			"columns": map[interface{}]interface{}{"time": "created", "order_id": "id"},
+		},
+	}, orderModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer source.Close()
+
+	records, err := source.FetchData(map[string]interface{}{
+		"start_at": time.Date(2024, 9, 1, 0, 0, 0, 0, berlin),
+		"end_at":   time.Date(2024, 9, 2, 0, 0, 0, 0, berlin),
+		"vars":     map[string]interface{}{"exclude": "Schmidt"},
+	})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{"time": time.Date(2024, 9, 1, 8, 0, 0, 0, berlin), "order_id": int64(1), "customer": "Müller", "amount": 10.5, "paid": true},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("FetchData() = %v, want %v", records, want)
+	}
+}
+
+func TestFetchDataSkipsInvalidRows(t *testing.T) {
+	source := &SQLiteSource{}
+	err := source.Init(map[string]interface{}{
+		"path":  createDB(t),
+		"query": "SELECT created AS time, id AS order_id, customer, amount, paid FROM orders",
+	}, orderModel)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer source.Close()
+
+	records, err := source.FetchData(map[string]interface{}{
+		"start_at": time.Now(),
+		"end_at":   time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+}
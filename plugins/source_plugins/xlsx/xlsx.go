@@ -0,0 +1,367 @@
+package xlsx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/mapping"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+	"github.com/xuri/excelize/v2"
+)
+
+type xlsxConfig struct {
+	fileio.FileOptions `yaml:",inline"`
+	Sheet              string   `yaml:"sheet"`
+	Range              string   `yaml:"range"`
+	HeaderRow          *int     `yaml:"header_row"`
+	Columns            []string `yaml:"columns"`
+	FillMerged         *bool    `yaml:"fill_merged"`
+	DateFormats        []string `yaml:"date_formats"`
+	Timezone           string   `yaml:"timezone"`
+}
+
+// XLSXSource reads a sheet of Excel workbooks. The rows can be limited to a
+// cell range or a defined name, merged cells repeat their value in every
+// cell of the merged area.
+type XLSXSource struct {
+	Model *models.Model
+	// Sheet defaults to the first sheet of the workbook.
+	Sheet string
+	// Range is a cell range like A3:F40 or a defined name of the workbook.
+	Range string
+	// HeaderRow is the 1 based row of the range holding the column names,
+	// 0 when the sheet has no header and Columns are used instead.
+	HeaderRow  int
+	Columns    []string
+	FillMerged bool
+	Mapping    *mapping.Mapping
+	Convert    convert.Options
+	*fileio.Files
+}
+
+func (s *XLSXSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := xlsxConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid xlsx config: %v", err)
+	}
+	s.Sheet = c.Sheet
+	s.Range = c.Range
+	if s.Range != "" && strings.Contains(s.Range, ":") {
+		if _, err := parseRange(s.Range); err != nil {
+			return err
+		}
+	}
+
+	s.Columns = c.Columns
+	switch {
+	case c.HeaderRow != nil:
+		if *c.HeaderRow < 0 {
+			return errors.New("header_row must not be negative")
+		}
+		s.HeaderRow = *c.HeaderRow
+	case len(c.Columns) > 0:
+		s.HeaderRow = 0
+	default:
+		s.HeaderRow = 1
+	}
+	s.FillMerged = c.FillMerged == nil || *c.FillMerged
+
+	var err error
+	if s.Mapping, err = mapping.Parse(cfg["mapping"], model); err != nil {
+		return err
+	}
+	if s.HeaderRow == 0 && len(s.Columns) == 0 && len(s.Mapping.Columns) == 0 {
+		return errors.New("columns or a mapping are required for sheets without header")
+	}
+
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{"02.01.2006 15:04:05", "02.01.2006", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	s.Files, err = fileio.NewFiles(c.FileOptions)
+	return err
+}
+
+func (s *XLSXSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.Read(params.FilePath, func(file fileio.File, emit fileio.Emit) error {
+		records, err := s.readFile(file.Path)
+		for _, record := range records {
+			emit(record)
+		}
+		return err
+	})
+}
+
+func (s *XLSXSource) readFile(path string) ([]map[string]interface{}, error) {
+	workbook, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer workbook.Close()
+
+	return s.read(workbook)
+}
+
+// cellRange is a 1 based, inclusive range of cells.
+type cellRange struct {
+	fromCol, fromRow, toCol, toRow int
+}
+
+// parseRange reads a range like A1:F20, absolute references ($A$1) are
+// accepted.
+func parseRange(ref string) (cellRange, error) {
+	parts := strings.Split(strings.ReplaceAll(ref, "$", ""), ":")
+	if len(parts) != 2 {
+		return cellRange{}, fmt.Errorf("invalid range %q", ref)
+	}
+	fromCol, fromRow, err := excelize.CellNameToCoordinates(parts[0])
+	if err != nil {
+		return cellRange{}, fmt.Errorf("invalid range %q: %v", ref, err)
+	}
+	toCol, toRow, err := excelize.CellNameToCoordinates(parts[1])
+	if err != nil {
+		return cellRange{}, fmt.Errorf("invalid range %q: %v", ref, err)
+	}
+	if toCol < fromCol || toRow < fromRow {
+		return cellRange{}, fmt.Errorf("invalid range %q: end before start", ref)
+	}
+	return cellRange{fromCol: fromCol, fromRow: fromRow, toCol: toCol, toRow: toRow}, nil
+}
+
+// area resolves the sheet and the range to read, a range without colon is
+// looked up in the defined names of the workbook.
+func (s *XLSXSource) area(workbook *excelize.File) (string, *cellRange, error) {
+	sheet := s.Sheet
+	ref := s.Range
+
+	if ref != "" && !strings.Contains(ref, ":") {
+		found := false
+		for _, name := range workbook.GetDefinedName() {
+			if name.Name != ref {
+				continue
+			}
+			i := strings.LastIndex(name.RefersTo, "!")
+			if i < 0 {
+				return "", nil, fmt.Errorf("defined name %s does not refer to cells: %s", ref, name.RefersTo)
+			}
+			sheet = strings.Trim(strings.TrimPrefix(name.RefersTo[:i], "="), "'")
+			ref = name.RefersTo[i+1:]
+			found = true
+			break
+		}
+		if !found {
+			return "", nil, fmt.Errorf("defined name %s not found", ref)
+		}
+	}
+
+	if sheet == "" {
+		sheets := workbook.GetSheetList()
+		if len(sheets) == 0 {
+			return "", nil, errors.New("workbook has no sheets")
+		}
+		sheet = sheets[0]
+	}
+
+	if ref == "" {
+		return sheet, nil, nil
+	}
+	r, err := parseRange(ref)
+	if err != nil {
+		return "", nil, err
+	}
+	return sheet, &r, nil
+}
+
+// read transforms the rows of the configured sheet into records.
+func (s *XLSXSource) read(workbook *excelize.File) ([]map[string]interface{}, error) {
+	sheet, area, err := s.area(workbook)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := workbook.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	if s.FillMerged {
+		if err := fillMerged(workbook, sheet, rows); err != nil {
+			return nil, err
+		}
+	}
+	rows = crop(rows, area)
+
+	date1904 := false
+	if props, err := workbook.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		date1904 = *props.Date1904
+	}
+
+	// Read the header, rows before the header row are skipped
+	header := s.Columns
+	if s.HeaderRow > len(rows) {
+		return nil, fmt.Errorf("header row %d not found in sheet %s", s.HeaderRow, sheet)
+	}
+	if s.HeaderRow > 0 && len(s.Columns) == 0 {
+		header = rows[s.HeaderRow-1]
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+	}
+	positions, err := s.Mapping.Resolve(s.Model, header)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for i, row := range rows[s.HeaderRow:] {
+		if emptyRow(row) {
+			continue
+		}
+		record := mapping.Row(positions, row)
+
+		transformedRecord, err := s.Transform(record, date1904)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"record": record,
+				"row":    s.HeaderRow + i + 1,
+				"error":  err,
+			}).Error("Error transforming record")
+			s.Files.Skip()
+			continue
+		}
+		records = append(records, transformedRecord)
+	}
+
+	return records, nil
+}
+
+// fillMerged copies the value of merged cells into every cell of the merged
+// area.
+func fillMerged(workbook *excelize.File, sheet string, rows [][]string) error {
+	merged, err := workbook.GetMergeCells(sheet)
+	if err != nil {
+		return err
+	}
+	for _, cell := range merged {
+		r, err := parseRange(cell.GetStartAxis() + ":" + cell.GetEndAxis())
+		if err != nil {
+			return err
+		}
+		if r.fromRow > len(rows) || r.fromCol > len(rows[r.fromRow-1]) {
+			continue
+		}
+		value := rows[r.fromRow-1][r.fromCol-1]
+		for row := r.fromRow; row <= r.toRow && row <= len(rows); row++ {
+			for len(rows[row-1]) < r.toCol {
+				rows[row-1] = append(rows[row-1], "")
+			}
+			for col := r.fromCol; col <= r.toCol; col++ {
+				rows[row-1][col-1] = value
+			}
+		}
+	}
+	return nil
+}
+
+// crop returns the cells inside the range.
+func crop(rows [][]string, area *cellRange) [][]string {
+	if area == nil {
+		return rows
+	}
+	var result [][]string
+	for row := area.fromRow; row <= area.toRow && row <= len(rows); row++ {
+		cells := rows[row-1]
+		var cropped []string
+		if area.fromCol <= len(cells) {
+			cropped = cells[area.fromCol-1 : min(area.toCol, len(cells))]
+		}
+		result = append(result, cropped)
+	}
+	return result
+}
+
+func emptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Transform converts the cell values into the column types. Datetime cells
+// hold serial dates, which are read as wall clock time in the configured
+// location.
+func (s *XLSXSource) Transform(record map[string]interface{}, date1904 bool) (map[string]interface{}, error) {
+	for _, column := range s.Model.Columns {
+		value, ok := record[column.Name].(string)
+		if !ok {
+			log.WithField("column", column.Name).Warn("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		data, err := s.convert(value, column.Type, date1904)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *XLSXSource) convert(value string, columnType models.ColumnType, date1904 bool) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	switch columnType {
+	case models.DateTime, models.DateTimeNullable:
+		serial, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return convert.String(value, columnType, s.Convert)
+		}
+		t, err := excelize.ExcelDateToTime(serial, date1904)
+		if err != nil {
+			return nil, err
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, s.Convert.Location), nil
+	case models.Int, models.BigInt:
+		// whole numbers may be stored as floats, e.g. 12.0
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f == float64(int64(f)) {
+			value = strconv.FormatInt(int64(f), 10)
+		}
+	case models.Bool:
+		switch value {
+		case "1":
+			return true, nil
+		case "0":
+			return false, nil
+		}
+	}
+	return convert.String(value, columnType, s.Convert)
+}
+
+func (s *XLSXSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("xlsx", func() plugins.Source {
+		return &XLSXSource{}
+	})
+}
@@ -0,0 +1,173 @@
+package xlsx
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+	"github.com/xuri/excelize/v2"
+)
+
+var budgetModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "kostenstelle", Type: models.String},
+		{Name: "monat", Type: models.DateTime},
+		{Name: "budget", Type: models.Float},
+		{Name: "stunden", Type: models.Int},
+		{Name: "freigegeben", Type: models.Bool},
+	},
+	Unique: []string{"kostenstelle", "monat"},
+}
+
+// writeWorkbook creates a budget sheet with a title row, merged cost
+// centers, serial dates and a defined name for the table.
+func writeWorkbook(t *testing.T) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Budget 2024"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		t.Fatal(err)
+	}
+	f.NewSheet("Notizen")
+
+	rows := [][]interface{}{
+		{"Budgetplanung 2024"},
+		{"Kostenstelle", "Monat", "Budget", "Stunden", "Freigegeben", "Kommentar"},
+		{"4711 Vertrieb", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1500.5, 12.0, true, "x"},
+		{nil, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 1250, 10, false},
+		{},
+		{"4712 Einkauf", "01.03.2024", "99,95", 3, true},
+		{"4713 Lager", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "kaputt", 1, true},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.MergeCell(sheet, "A3", "A4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetDefinedName(&excelize.DefinedName{Name: "Planung", RefersTo: "'Budget 2024'!$A$2:$E$7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "budget.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetchData(t *testing.T) {
+	path := writeWorkbook(t)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	want := []map[string]interface{}{
+		{"kostenstelle": "4711 Vertrieb", "monat": time.Date(2024, 1, 1, 0, 0, 0, 0, berlin), "budget": 1500.5, "stunden": 12, "freigegeben": true},
+		{"kostenstelle": "4711 Vertrieb", "monat": time.Date(2024, 2, 1, 0, 0, 0, 0, berlin), "budget": 1250.0, "stunden": 10, "freigegeben": false},
+		{"kostenstelle": "4712 Einkauf", "monat": time.Date(2024, 3, 1, 0, 0, 0, 0, berlin), "budget": 99.95, "stunden": 3, "freigegeben": true},
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   []map[string]interface{}
+	}{
+		{
+			name:   "sheet with header row",
+			config: map[string]interface{}{"sheet": "Budget 2024", "header_row": 2},
+			want:   want,
+		},
+		{
+			name:   "cell range",
+			config: map[string]interface{}{"sheet": "Budget 2024", "range": "A2:E4"},
+			want:   want[:2],
+		},
+		{
+			name:   "defined name",
+			config: map[string]interface{}{"range": "Planung"},
+			want:   want,
+		},
+		{
+			name: "columns without header",
+			config: map[string]interface{}{
+				"range":   "A3:E4",
+				"columns": []interface{}{"kostenstelle", "monat", "budget", "stunden", "freigegeben"},
+			},
+			want: want[:2],
+		},
+		{
+			name: "merged cells not filled",
+			config: map[string]interface{}{
+				"range":       "A2:E4",
+				"fill_merged": false,
+			},
+			want: []map[string]interface{}{want[0], {"kostenstelle": "", "stunden": 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.config["columns"]; !ok {
+				tt.config["mapping"] = map[interface{}]interface{}{"case_insensitive": true}
+			}
+			source := &XLSXSource{}
+			if err := source.Init(tt.config, budgetModel); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			// the sheet holds a broken row, FetchData fails the file
+			records, err := source.readFile(path)
+			if err != nil {
+				t.Fatalf("readFile() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d: %v", len(tt.want), len(records), records)
+			}
+			for i := range tt.want {
+				for column, value := range tt.want[i] {
+					got := records[i][column]
+					if ts, ok := value.(time.Time); ok {
+						if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+							t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+						}
+						continue
+					}
+					if !reflect.DeepEqual(got, value) {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFetchDataErrors(t *testing.T) {
+	path := writeWorkbook(t)
+
+	tests := map[string]map[string]interface{}{
+		"unknown sheet":        {"sheet": "Fehlt"},
+		"unknown defined name": {"range": "Fehlt"},
+		"header after range":   {"range": "A2:E3", "header_row": 5},
+		"strict mapping":       {"header_row": 2, "mapping": map[interface{}]interface{}{"strict": true}},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			source := &XLSXSource{}
+			if err := source.Init(cfg, budgetModel); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := source.FetchData(map[string]interface{}{"file_path": path}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if err := (&XLSXSource{}).Init(map[string]interface{}{"range": "A5:A1"}, budgetModel); err == nil {
+		t.Error("expected error for invalid range")
+	}
+}
@@ -0,0 +1,195 @@
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Talk-Point/databridge/config"
+	"github.com/Talk-Point/databridge/models"
+	"github.com/Talk-Point/databridge/pkg/convert"
+	"github.com/Talk-Point/databridge/pkg/fileio"
+	"github.com/Talk-Point/databridge/pkg/xmlpath"
+	"github.com/Talk-Point/databridge/plugins"
+	log "github.com/sirupsen/logrus"
+)
+
+type xmlConfig struct {
+	fileio.FileOptions        `yaml:",inline"`
+	fileio.CompressionOptions `yaml:",inline"`
+	Records                   string            `yaml:"records"`
+	Fields                    map[string]string `yaml:"fields"`
+	DateFormats               []string          `yaml:"date_formats"`
+	Timezone                  string            `yaml:"timezone"`
+}
+
+// XMLSource streams XML files and reads every element selected by Records
+// as a record. Only the selected elements are held in memory, so large
+// catalogs can be read element by element.
+type XMLSource struct {
+	Model   *models.Model
+	Records xmlpath.Path
+	// Fields holds the selector of every model column relative to the
+	// record element, it defaults to the child element named like the
+	// column.
+	Fields      map[string]xmlpath.Path
+	Convert     convert.Options
+	Compression fileio.CompressionOptions
+	*fileio.Files
+}
+
+func (s *XMLSource) Init(cfg map[string]interface{}, model *models.Model) error {
+	s.Model = model
+
+	c := xmlConfig{}
+	if err := config.Decode(cfg, &c); err != nil {
+		return fmt.Errorf("invalid xml config: %v", err)
+	}
+
+	if c.Records == "" {
+		return errors.New("records selector is missing")
+	}
+	var err error
+	if s.Records, err = xmlpath.Parse(c.Records); err != nil {
+		return err
+	}
+	if !s.Records.IsRecord() {
+		return fmt.Errorf("invalid records selector %q: must start with / or //", c.Records)
+	}
+	if s.Records.HasPosition() {
+		return fmt.Errorf("invalid records selector %q: positions are not supported, use an attribute predicate", c.Records)
+	}
+
+	columns := make(map[string]bool, len(model.Columns))
+	for _, column := range model.Columns {
+		columns[column.Name] = true
+	}
+	for column := range c.Fields {
+		if !columns[column] {
+			return fmt.Errorf("field %s is not a model column", column)
+		}
+	}
+	s.Fields = make(map[string]xmlpath.Path, len(model.Columns))
+	for _, column := range model.Columns {
+		selector, ok := c.Fields[column.Name]
+		if !ok {
+			selector = column.Name
+		}
+		if s.Fields[column.Name], err = xmlpath.Parse(selector); err != nil {
+			return fmt.Errorf("field %s: %v", column.Name, err)
+		}
+	}
+
+	s.Convert.DateLayouts = c.DateFormats
+	if len(s.Convert.DateLayouts) == 0 {
+		s.Convert.DateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	}
+	if s.Convert.Location, err = convert.ParseLocation(c.Timezone); err != nil {
+		return err
+	}
+
+	if s.Files, err = fileio.NewFiles(c.FileOptions); err != nil {
+		return err
+	}
+	s.Compression = c.CompressionOptions
+	return s.Compression.Validate()
+}
+
+func (s *XMLSource) FetchData(opts map[string]interface{}) ([]map[string]interface{}, error) {
+	params, err := plugins.ParseFetchOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Files.ReadStreams(params.FilePath, s.Compression, s.read)
+}
+
+// read walks the tokens of the document and decodes every selected element
+// on its own, the transformed records are passed to fn.
+func (s *XMLSource) read(r io.Reader, fn func(record map[string]interface{})) error {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = fileio.CharsetReader
+
+	var stack []xml.StartElement
+	index := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Copy())
+			if !s.Records.Match(stack) {
+				continue
+			}
+			node := &xmlpath.Node{}
+			if err := decoder.DecodeElement(node, &t); err != nil {
+				return err
+			}
+			// DecodeElement consumed the end element
+			stack = stack[:len(stack)-1]
+			index++
+
+			record, err := s.Transform(node)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"record": index,
+					"error":  err,
+				}).Error("Error transforming record")
+				s.Files.Skip()
+				continue
+			}
+			fn(record)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Transform selects the column values of a record element and converts
+// them. Missing elements and empty values of non string columns are nil.
+func (s *XMLSource) Transform(node *xmlpath.Node) (map[string]interface{}, error) {
+	record := make(map[string]interface{}, len(s.Model.Columns))
+	for _, column := range s.Model.Columns {
+		value, ok := s.Fields[column.Name].Get(node)
+		if !ok {
+			log.WithField("column", column.Name).Debug("Column not found in record")
+			record[column.Name] = nil
+			continue
+		}
+		if value == "" && column.Type != models.String {
+			record[column.Name] = nil
+			continue
+		}
+		data, err := convert.Value(value, column.Type, s.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("error converting column %s: %v", column.Name, err)
+		}
+		record[column.Name] = data
+	}
+	return record, nil
+}
+
+func (s *XMLSource) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.RegisterSource("xml", func() plugins.Source {
+		return &XMLSource{}
+	})
+}
@@ -0,0 +1,146 @@
+package xml
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/databridge/models"
+)
+
+var productModel = &models.Model{
+	Columns: []models.Column{
+		{Name: "sku", Type: models.String},
+		{Name: "name", Type: models.String},
+		{Name: "price", Type: models.Float},
+		{Name: "currency", Type: models.String},
+		{Name: "stock", Type: models.Int},
+		{Name: "image", Type: models.String},
+		{Name: "material", Type: models.String},
+		{Name: "updated", Type: models.DateTimeNullable},
+	},
+	Unique: []string{"sku"},
+}
+
+func TestFetchData(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name    string
+		records string
+		want    []map[string]interface{}
+	}{
+		{
+			name:    "absolute",
+			records: "/catalog/products/product",
+			want: []map[string]interface{}{
+				{
+					"sku": "A-100", "name": "Schraube M4", "price": 0.10, "currency": "EUR", "stock": 1200,
+					"image": "b.jpg", "material": "Edelstahl", "updated": time.Date(2024, 9, 1, 8, 30, 0, 0, berlin),
+				},
+				{
+					"sku": "A-200", "name": "Mutter M4 für Schraube", "price": 0.05, "currency": "EUR", "stock": nil,
+					"image": nil, "material": nil, "updated": time.Date(2024, 9, 2, 0, 0, 0, 0, berlin),
+				},
+			},
+		},
+		{
+			name:    "descendant with predicate",
+			records: "//cat:product[@type='main']",
+			want: []map[string]interface{}{
+				{"sku": "A-100"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &XMLSource{}
+			err := source.Init(map[string]interface{}{
+				"path":    "testdata/catalog.xml",
+				"records": tt.records,
+				"fields": map[interface{}]interface{}{
+					"sku":      "@sku",
+					"currency": "price/@currency",
+					"image":    "images/image[2]",
+					"material": "attr[@name='material']",
+				},
+			}, productModel)
+			if err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			// the catalog holds a broken record, FetchData fails the file
+			file, err := os.Open("testdata/catalog.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var records []map[string]interface{}
+			if err := source.read(file, func(record map[string]interface{}) { records = append(records, record) }); err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d: %v", len(tt.want), len(records), records)
+			}
+			for i := range tt.want {
+				for column, value := range tt.want[i] {
+					got := records[i][column]
+					if ts, ok := value.(time.Time); ok {
+						if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(ts) {
+							t.Errorf("record %d column %s = %v, want %v", i, column, got, value)
+						}
+						continue
+					}
+					if !reflect.DeepEqual(got, value) {
+						t.Errorf("record %d column %s = %#v, want %#v", i, column, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  map[string]interface{}
+	}{
+		{"missing records", map[string]interface{}{}},
+		{"relative records", map[string]interface{}{"records": "product"}},
+		{"records position", map[string]interface{}{"records": "//product[2]"}},
+		{"records parent position", map[string]interface{}{"records": "/catalog/products[1]/product"}},
+		{"unknown field", map[string]interface{}{
+			"records": "//product",
+			"fields":  map[interface{}]interface{}{"ean": "@ean"},
+		}},
+		{"invalid field", map[string]interface{}{
+			"records": "//product",
+			"fields":  map[interface{}]interface{}{"sku": "a[0]"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &XMLSource{}
+			if err := source.Init(tt.cfg, productModel); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestFetchDataTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.xml")
+	if err := os.WriteFile(path, []byte("<catalog><product><sku>1</sku></product>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := &XMLSource{}
+	if err := source.Init(map[string]interface{}{"path": path, "records": "//product"}, productModel); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if _, err := source.FetchData(map[string]interface{}{}); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}